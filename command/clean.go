@@ -0,0 +1,308 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/libcompose/docker"
+	"github.com/samalba/dockerclient"
+	"gopkg.in/yaml.v2"
+)
+
+type CleanCommand struct {
+	Meta
+}
+
+func (c *CleanCommand) Run(args []string) int {
+
+	var insecure, pods, force bool
+	var k8sVersion string
+	var configs stringSlice
+	flags := c.Meta.flagSet("clean")
+	flags.BoolVar(&insecure, "insecure", false, "")
+	flags.BoolVar(&pods, "pods", false, "")
+	flags.BoolVar(&force, "force", false, "")
+	flags.BoolVar(&force, "yes", false, "")
+	flags.Var(&configs, "config", "")
+	flags.StringVar(&k8sVersion, "k8s-version", "", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	name := c.clusterName()
+	state, err := LoadClusterState(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
+		return 1
+	}
+
+	// Images of the cluster are read from its recorded compose files
+	// and version, unless version is given explicitly.
+	var compose []byte
+	if k8sVersion != "" {
+		// Only images are read, so ports don't matter
+		params, err := newComposeParams(name, k8sVersion, 0)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Invalid kubernetes version: %s", err))
+			return 1
+		}
+		compose, err = loadCompose(configPaths(configs), params)
+	} else {
+		compose, err = clusterCompose(name, configs)
+	}
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read compose file: %s", err))
+		return 1
+	}
+
+	refs, err := composeImages(compose)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read images from compose file: %s", err))
+		return 1
+	}
+
+	// Set up docker client
+	clientFactory, err := docker.NewDefaultClientFactory(
+		docker.ClientOpts{
+			TLS: !insecure,
+		},
+	)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to construct Docker client: %s", err))
+		return 1
+	}
+
+	client := clientFactory.Create(nil)
+
+	// Pod containers of the cluster are removed with their images
+	var podContainers []dockerclient.Container
+	podIDs := make(map[string]bool)
+	if pods {
+		if rootDir := podRootDir(c.Ui, name, state); rootDir != "" {
+			podContainers, err = listPodContainers(client, rootDir)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Failed to list containers: %s", err))
+				return 1
+			}
+		}
+
+		for _, container := range podContainers {
+			refs = appendImage(refs, container.Image)
+			podIDs[container.Id] = true
+		}
+	}
+
+	images, err := client.ListImages(false)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to list images: %s", err))
+		return 1
+	}
+
+	// Only images which are pulled can be removed
+	targets := make([]string, 0, len(refs))
+	sizes := make(map[string]int64)
+	ids := make(map[string]string)
+	for _, ref := range refs {
+		if image := findImage(images, ref); image != nil {
+			targets = append(targets, ref)
+			sizes[ref] = image.VirtualSize
+			ids[ref] = image.Id
+		}
+	}
+
+	if len(targets) < 1 {
+		c.Ui.Info("There are no images to remove")
+		return 0
+	}
+
+	// Refuse to remove images which are used by containers
+	containers, err := client.ListContainers(true, false, "")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to list containers: %s", err))
+		return 1
+	}
+
+	inUse := false
+	for _, container := range containers {
+		if podIDs[container.Id] {
+			continue
+		}
+
+		for _, ref := range targets {
+			if normalizeImage(container.Image) == ref || container.Image == ids[ref] {
+				c.Ui.Error(fmt.Sprintf(
+					"Image %s is used by container %s", ref, container.Names[0]))
+				inUse = true
+			}
+		}
+	}
+
+	if inUse {
+		c.Ui.Error("Remove the containers first (e.g., by `destroy` command)")
+		return 1
+	}
+
+	if len(podContainers) > 0 {
+		c.Ui.Output("Are you sure you want to remove below containers (these are created by kubernetes of the cluster)?")
+		for _, container := range podContainers {
+			c.Ui.Output(fmt.Sprintf("  %s", container.Names[0]))
+		}
+		c.Ui.Output("And below images?")
+	} else {
+		c.Ui.Output("Are you sure you want to remove below images?")
+	}
+	var total int64
+	for _, ref := range targets {
+		c.Ui.Output(fmt.Sprintf("  %s (%s)", ref, humanSize(sizes[ref])))
+		total += sizes[ref]
+	}
+	c.Ui.Output(fmt.Sprintf("Total: %s (layers shared with other images are not freed)", humanSize(total)))
+
+	if yes, err := confirm(c.Ui, force); !yes || err != nil {
+		if err == nil {
+			c.Ui.Info("Images will not be removed, since the confirmation")
+			return 0
+		}
+		c.Ui.Error(fmt.Sprintf(
+			"Terminate to clean: %s", err.Error()))
+		return 1
+	}
+
+	exitCode := 0
+	resultCh, errCh := removeContainers(client, podContainers, true, true)
+	go func() {
+		for res := range resultCh {
+			c.Ui.Output(fmt.Sprintf(
+				"Successfully removed %s", res.Names[0]))
+		}
+	}()
+
+	for err := range errCh {
+		c.Ui.Error(fmt.Sprintf("Error: %s", err))
+		exitCode = 1
+	}
+
+	for _, ref := range targets {
+		if _, err := client.RemoveImage(ref, false); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error: failed to remove %s: %s", ref, err))
+			exitCode = 1
+			continue
+		}
+		c.Ui.Output(fmt.Sprintf("Successfully removed %s", ref))
+	}
+
+	return exitCode
+}
+
+func (c *CleanCommand) Synopsis() string {
+	return "Remove docker images which are pulled for kubernetes cluster"
+}
+
+func (c *CleanCommand) Help() string {
+	helpText := `Remove docker images which are pulled for kubernetes cluster
+(images in compose file, e.g., etcd and hyperkube). Images which are
+used by containers are not removed.
+
+Options:
+
+  -name=NAME      Name of the cluster. Default is "default".
+
+  -config=PATH    Compose file to read images from (See "up -help").
+                  By default, compose files recorded at up are used.
+
+  -k8s-version    Kubernetes version to remove images of. By default,
+                  the version recorded at up (or the default version
+                  of the catalog) is used.
+
+  -pods           Also remove containers which are created by kubernetes
+                  of the cluster (pod containers) and their images.
+
+  -force, -yes    Remove images without confirmation. Without it,
+                  confirmation is asked and clean fails if stdin is
+                  not a terminal (e.g., in CI).
+
+  -insecure       Allow insecure non-TLS connection to docker client.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// composeImages returns image references which are used in compose.
+func composeImages(compose []byte) ([]string, error) {
+	var services map[string]struct {
+		Image string `yaml:"image"`
+	}
+	if err := yaml.Unmarshal(compose, &services); err != nil {
+		return nil, err
+	}
+
+	// Sort by service name to show in stable order
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	refs := make([]string, 0, len(services))
+	for _, name := range names {
+		if image := services[name].Image; image != "" {
+			refs = appendImage(refs, image)
+		}
+	}
+
+	return refs, nil
+}
+
+// appendImage appends normalized image reference without duplication.
+func appendImage(refs []string, image string) []string {
+	image = normalizeImage(image)
+	for _, ref := range refs {
+		if ref == image {
+			return refs
+		}
+	}
+	return append(refs, image)
+}
+
+// normalizeImage adds "latest" tag if image reference has no tag.
+func normalizeImage(image string) string {
+	// Tag is after the last colon which is not a part of registry host (e.g., localhost:5000/foo)
+	if i := strings.LastIndex(image, ":"); i < 0 || strings.Contains(image[i:], "/") {
+		return image + ":latest"
+	}
+	return image
+}
+
+// findImage returns image which is tagged as ref.
+func findImage(images []*dockerclient.Image, ref string) *dockerclient.Image {
+	for _, image := range images {
+		for _, tag := range image.RepoTags {
+			if tag == ref {
+				return image
+			}
+		}
+	}
+	return nil
+}
+
+// humanSize returns human readable size (e.g., 12.3 MB).
+func humanSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	s := float64(size)
+	i := 0
+	for s >= 1000 && i < len(units)-1 {
+		s /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", s, units[i])
+}
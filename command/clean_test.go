@@ -0,0 +1,60 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestCleanCommand_implement(t *testing.T) {
+	var _ cli.Command = &CleanCommand{}
+}
+
+func TestComposeImages(t *testing.T) {
+	compose := []byte(`
+proxy:
+  image: gcr.io/google_containers/hyperkube:v0.21.2
+etcd:
+  image: gcr.io/google_containers/etcd:2.0.9
+master:
+  image: gcr.io/google_containers/hyperkube:v0.21.2
+`)
+
+	refs, err := composeImages(compose)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expect := []string{
+		"gcr.io/google_containers/etcd:2.0.9",
+		"gcr.io/google_containers/hyperkube:v0.21.2",
+	}
+	if !reflect.DeepEqual(refs, expect) {
+		t.Fatalf("expect %v to eq %v", refs, expect)
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	cases := []struct {
+		image  string
+		expect string
+	}{
+		{"busybox", "busybox:latest"},
+		{"busybox:1.0", "busybox:1.0"},
+		{"localhost:5000/busybox", "localhost:5000/busybox:latest"},
+		{"localhost:5000/busybox:1.0", "localhost:5000/busybox:1.0"},
+	}
+
+	for _, tc := range cases {
+		if got := normalizeImage(tc.image); got != tc.expect {
+			t.Fatalf("expect %q to eq %q", got, tc.expect)
+		}
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	if got, want := humanSize(123456789), "123.5 MB"; got != want {
+		t.Fatalf("expect %q to eq %q", got, want)
+	}
+}
@@ -0,0 +1,254 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/docker/libcompose/docker"
+	"github.com/docker/libcompose/project"
+	"github.com/mitchellh/cli"
+	"github.com/samalba/dockerclient"
+)
+
+const (
+	// ProjectName is libcompose project name of the default cluster.
+	// Project of the named cluster is suffixed by its name.
+	ProjectName = "boot2k8s"
+
+	// Labels which libcompose puts on containers it creates.
+	LabelProject = "io.docker.compose.project"
+	LabelService = "io.docker.compose.service"
+
+	// LabelCluster is label which has cluster name (see k8s.yml).
+	LabelCluster = "io.boot2k8s.cluster"
+
+	// KubeletRootDirBase is directory on docker host under which each
+	// cluster has its kubelet root directory.
+	KubeletRootDirBase = "/var/lib/boot2k8s"
+)
+
+// Services which are defined in k8s.yml. Master runs kubelet.
+const (
+	ServiceEtcd              = "etcd"
+	ServiceAPIServer         = "apiserver"
+	ServiceControllerManager = "controller-manager"
+	ServiceScheduler         = "scheduler"
+	ServiceMaster            = "master"
+	ServiceProxy             = "proxy"
+)
+
+// projectName returns libcompose project name of the cluster.
+// The default cluster uses ProjectName as it is for compatibility
+// with the cluster which is created before named cluster is introduced.
+func projectName(name string) string {
+	if name == DefaultName {
+		return ProjectName
+	}
+	return ProjectName + name
+}
+
+// newProject sets up libcompose project of the cluster from compose.
+func newProject(name string, compose []byte, clientFactory docker.ClientFactory) (*project.Project, error) {
+	context := &docker.Context{
+		Context: project.Context{
+			Log:          false,
+			ComposeBytes: compose,
+			ProjectName:  projectName(name),
+		},
+		ClientFactory: clientFactory,
+	}
+
+	return docker.NewProject(context)
+}
+
+// listServiceContainers returns containers of the given service of the
+// cluster project. If service is empty, it returns all containers of
+// the project. Stopped containers are included.
+func listServiceContainers(client dockerclient.Client, name, service string) ([]dockerclient.Container, error) {
+	labels := []string{fmt.Sprintf("%s=%s", LabelProject, projectName(name))}
+	if service != "" {
+		labels = append(labels, fmt.Sprintf("%s=%s", LabelService, service))
+	}
+
+	// Marshaling to post filter as API request
+	filterStr, err := json.Marshal(map[string][]string{"label": labels})
+	if err != nil {
+		return nil, err
+	}
+
+	return client.ListContainers(true, false, (string)(filterStr))
+}
+
+// listClusterContainers returns containers of all clusters on the
+// docker daemon grouped by cluster name. Stopped containers are included.
+func listClusterContainers(client dockerclient.Client) (map[string][]dockerclient.Container, error) {
+	// Marshaling to post filter as API request
+	filterStr, err := json.Marshal(map[string][]string{"label": []string{LabelProject}})
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := client.ListContainers(true, false, (string)(filterStr))
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make(map[string][]dockerclient.Container)
+	for _, container := range containers {
+		project := container.Labels[LabelProject]
+		if !strings.HasPrefix(project, ProjectName) {
+			continue
+		}
+
+		name, ok := container.Labels[LabelCluster]
+		if !ok {
+			// Only the default cluster can be created without the label
+			if project != ProjectName {
+				continue
+			}
+			name = DefaultName
+		}
+
+		clusters[name] = append(clusters[name], container)
+	}
+
+	return clusters, nil
+}
+
+// clusterNames returns sorted names of clusters.
+func clusterNames(clusters map[string][]dockerclient.Container) []string {
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasRunning returns true if any of containers is running.
+func hasRunning(containers []dockerclient.Container) bool {
+	for _, container := range containers {
+		if isRunning(container) {
+			return true
+		}
+	}
+	return false
+}
+
+// conflictingCluster returns name of the running cluster except the
+// given one whose ports are the same as the cluster of offset (see
+// clusterPorts). Port offset of each cluster is read from its state.
+// If there is no such cluster, it returns empty string.
+func conflictingCluster(clusters map[string][]dockerclient.Container, name string, offset int) (string, error) {
+	for _, other := range clusterNames(clusters) {
+		if other == name || !hasRunning(clusters[other]) {
+			continue
+		}
+
+		ports, err := loadClusterPorts(other)
+		if err != nil {
+			return "", err
+		}
+
+		if ports == clusterPorts(offset) {
+			return other, nil
+		}
+	}
+	return "", nil
+}
+
+// isRunning returns true if container is running. It's detected
+// from status string which API returns (e.g., "Up 3 minutes").
+func isRunning(container dockerclient.Container) bool {
+	return strings.HasPrefix(container.Status, "Up")
+}
+
+// kubeletRootDir returns kubelet root directory of the cluster.
+func kubeletRootDir(name string) string {
+	return path.Join(KubeletRootDirBase, name, "kubelet")
+}
+
+// podRootDir returns kubelet root directory recorded in state of the
+// named cluster, which identifies its pod containers. If it's not
+// recorded (e.g., the cluster is created by older boot2k8s), they can't
+// be told from pod containers of other kubelets, so it warns via ui that
+// they are skipped and returns empty.
+func podRootDir(ui cli.Ui, name string, state *ClusterState) string {
+	if state != nil && state.KubeletRootDir != "" {
+		return state.KubeletRootDir
+	}
+
+	ui.Warn(fmt.Sprintf(
+		"Pod containers of cluster %q can't be identified (kubelet root directory is not recorded), so they are skipped", name))
+	return ""
+}
+
+// listPodContainers returns containers which are created by kubelet
+// whose root directory is rootDir. Kubelet mounts files under its root
+// directory (e.g., /dev/termination-log) into pod containers, so they
+// are found by the mounts. Infra containers (POD), which have no mounts,
+// are found by pod UID in their names. Stopped containers are included.
+func listPodContainers(client dockerclient.Client, rootDir string) ([]dockerclient.Container, error) {
+	if rootDir == "" {
+		return nil, errors.New("kubelet root directory is not given")
+	}
+
+	// Marshaling to post filter as API request
+	filterStr, err := json.Marshal(FilterK8SRelated)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := client.ListContainers(true, false, (string)(filterStr))
+	if err != nil {
+		return nil, err
+	}
+
+	podsDir := path.Join(rootDir, "pods") + "/"
+	uids := make(map[string]bool)
+	for _, container := range containers {
+		info, err := client.InspectContainer(container.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.HostConfig == nil {
+			continue
+		}
+
+		for _, bind := range info.HostConfig.Binds {
+			if strings.HasPrefix(bind, podsDir) {
+				uids[podUID(container)] = true
+				break
+			}
+		}
+	}
+
+	pods := make([]dockerclient.Container, 0, len(uids))
+	for _, container := range containers {
+		if uid := podUID(container); uid != "" && uids[uid] {
+			pods = append(pods, container)
+		}
+	}
+
+	return pods, nil
+}
+
+// podUID returns UID of the pod which container belongs to. It's parsed
+// from the name which kubelet gives (k8s_CONTAINER.HASH_POD_NAMESPACE_UID_RANDOM).
+// If the name is not in the format, it returns empty string.
+func podUID(container dockerclient.Container) string {
+	if len(container.Names) < 1 {
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimPrefix(container.Names[0], "/"), "_")
+	if len(parts) < 6 || parts[0] != "k8s" {
+		return ""
+	}
+	return parts[4]
+}
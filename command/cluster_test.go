@@ -0,0 +1,177 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/go-homedir"
+	"github.com/samalba/dockerclient"
+)
+
+func TestProjectName(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{DefaultName, "boot2k8s"},
+		{"dev", "boot2k8sdev"},
+	}
+
+	for _, tc := range cases {
+		if got := projectName(tc.name); got != tc.want {
+			t.Fatalf("expect %q to eq %q", got, tc.want)
+		}
+	}
+}
+
+func TestClusterName(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"", DefaultName},
+		{"Dev", "dev"},
+		{"my-cluster_1", "mycluster1"},
+		{"---", DefaultName},
+	}
+
+	for _, tc := range cases {
+		m := &Meta{Name: tc.name}
+		if got := m.clusterName(); got != tc.want {
+			t.Fatalf("expect %q to eq %q", got, tc.want)
+		}
+	}
+}
+
+func TestPodRootDir(t *testing.T) {
+	ui := new(cli.MockUi)
+	state := &ClusterState{Name: "dev", KubeletRootDir: kubeletRootDir("dev")}
+	if got := podRootDir(ui, "dev", state); got != kubeletRootDir("dev") {
+		t.Fatalf("expect %q to eq %q", got, kubeletRootDir("dev"))
+	}
+
+	// Cluster created by older boot2k8s
+	for _, state := range []*ClusterState{nil, {Name: "dev"}} {
+		ui := new(cli.MockUi)
+		if got := podRootDir(ui, "dev", state); got != "" {
+			t.Fatalf("expect %q to be empty", got)
+		}
+
+		if !strings.Contains(ui.ErrorWriter.String(), "skipped") {
+			t.Fatalf("expect warning: %q", ui.ErrorWriter.String())
+		}
+	}
+}
+
+func TestConflictingCluster(t *testing.T) {
+	home, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	// default has no state (created by older boot2k8s)
+	if err := (&ClusterState{Name: "dev", PortOffset: 1}).Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	clusters := map[string][]dockerclient.Container{
+		"default": []dockerclient.Container{{Status: "Up 3 minutes"}},
+		"dev":     []dockerclient.Container{{Status: "Up 3 minutes"}},
+	}
+
+	cases := []struct {
+		name   string
+		offset int
+		expect string
+	}{
+		{"new", 0, "default"},
+		{"new", 1, "dev"},
+		{"new", 2, ""},
+		{"dev", 1, ""},
+	}
+
+	for i, tc := range cases {
+		got, err := conflictingCluster(clusters, tc.name, tc.offset)
+		if err != nil {
+			t.Fatalf("#%d err: %s", i, err)
+		}
+
+		if got != tc.expect {
+			t.Fatalf("#%d expect %q to eq %q", i, got, tc.expect)
+		}
+	}
+}
+
+func TestPodUID(t *testing.T) {
+	cases := []struct {
+		name   string
+		expect string
+	}{
+		{"/k8s_nginx.d7d3eb2f_nginx-1fr2w_default_7b8e0c25-4d3a-11e5-a0f3-0800277e5f4c_8a9e1c0f", "7b8e0c25-4d3a-11e5-a0f3-0800277e5f4c"},
+		{"/k8s_POD.e4cc795_nginx-1fr2w_default_7b8e0c25-4d3a-11e5-a0f3-0800277e5f4c_3b7d2a11", "7b8e0c25-4d3a-11e5-a0f3-0800277e5f4c"},
+		{"/boot2k8s_master_1", ""},
+	}
+
+	for i, tc := range cases {
+		container := dockerclient.Container{Names: []string{tc.name}}
+		if got := podUID(container); got != tc.expect {
+			t.Fatalf("#%d expect %q to eq %q", i, got, tc.expect)
+		}
+	}
+}
+
+// podsClient returns containers and their binds.
+type podsClient struct {
+	dockerclient.Client
+	containers []dockerclient.Container
+	binds      map[string][]string
+}
+
+func (c *podsClient) ListContainers(all, size bool, filters string) ([]dockerclient.Container, error) {
+	return c.containers, nil
+}
+
+func (c *podsClient) InspectContainer(id string) (*dockerclient.ContainerInfo, error) {
+	return &dockerclient.ContainerInfo{
+		Id:         id,
+		HostConfig: &dockerclient.HostConfig{Binds: c.binds[id]},
+	}, nil
+}
+
+func TestListPodContainers(t *testing.T) {
+	client := &podsClient{
+		containers: []dockerclient.Container{
+			{Id: "1", Names: []string{"/k8s_nginx.d7d3eb2f_nginx_default_uid1_8a9e1c0f"}},
+			{Id: "2", Names: []string{"/k8s_POD.e4cc795_nginx_default_uid1_3b7d2a11"}},
+			{Id: "3", Names: []string{"/k8s_nginx.d7d3eb2f_nginx_default_uid2_5c1f0e2a"}},
+			{Id: "4", Names: []string{"/k8s_POD.e4cc795_nginx_default_uid2_9d0a4b3c"}},
+		},
+		binds: map[string][]string{
+			"1": {"/var/lib/boot2k8s/dev/kubelet/pods/uid1/containers/nginx/8a9e1c0f:/dev/termination-log"},
+			"3": {"/var/lib/kubelet/pods/uid2/containers/nginx/5c1f0e2a:/dev/termination-log"},
+		},
+	}
+
+	pods, err := listPodContainers(client, kubeletRootDir("dev"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(pods) != 2 || pods[0].Id != "1" || pods[1].Id != "2" {
+		t.Fatalf("expect containers of uid1: %v", pods)
+	}
+
+	// Without root directory, pod containers of the cluster can't be found
+	if _, err := listPodContainers(client, ""); err == nil {
+		t.Fatal("expect listing without root directory to fail")
+	}
+}
@@ -0,0 +1,334 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/tcnksm/boot2kubernetes/config"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// EnvConfig is environmental variable to specify compose files
+	// which are used instead of embedded k8s.yml. Multiple files
+	// are separated by os.PathListSeparator (':' on unix).
+	EnvConfig = "BOOT2K8S_CONFIG"
+
+	// DefaultConfig is name of embedded compose file.
+	DefaultConfig = "k8s.yml"
+
+	// TemplateExt is extension of compose files which are rendered as
+	// text/template with ComposeParams (e.g., k8s.yml.tmpl). Other
+	// compose files given by user are used as they are.
+	TemplateExt = ".tmpl"
+)
+
+// ComposeParams is parameters to render compose file. Embedded k8s.yml
+// and compose files which have TemplateExt are treated as text/template,
+// so they can refer these values (e.g., {{.Hyperkube}} and {{.EtcdPort}}).
+type ComposeParams struct {
+	*K8sVersion
+	ClusterPorts
+
+	// Name is name of the cluster.
+	Name string
+
+	// DataDir is directory on docker host which is mounted as
+	// etcd data directory. If empty, data is kept in container.
+	DataDir string
+
+	// KubeletRootDir is root directory of kubelet. It's unique to the
+	// cluster, so pod containers of the cluster can be found by it
+	// (see listPodContainers). loadCompose always sets it to kubelet.
+	// Empty if the kubernetes version has no RootDirFlag.
+	KubeletRootDir string
+}
+
+// KubeletRootDirFlag returns kubelet flag which sets KubeletRootDir.
+// Spelling of the flag is taken from the version catalog.
+func (p *ComposeParams) KubeletRootDirFlag() string {
+	if p.KubeletRootDir == "" || p.RootDirFlag == "" {
+		return ""
+	}
+
+	return p.RootDirFlag + "=" + p.KubeletRootDir
+}
+
+// newComposeParams returns ComposeParams for the given cluster,
+// kubernetes version and port offset (see clusterPorts). If version is
+// empty, default version of the catalog is used. Flags in the catalog
+// are rendered with ports of the cluster.
+func newComposeParams(name, version string, offset int) (*ComposeParams, error) {
+	catalog, err := LoadVersionCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	k8sVersion, err := catalog.Lookup(version)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &ComposeParams{
+		ClusterPorts: clusterPorts(offset),
+		Name:         name,
+	}
+
+	// Older kubelet which has no flag runs with its default directory
+	if k8sVersion.RootDirFlag != "" {
+		params.KubeletRootDir = kubeletRootDir(name)
+	}
+
+	// Copy not to modify the catalog
+	rendered := *k8sVersion
+	for _, flags := range []*string{
+		&rendered.KubeletFlags,
+		&rendered.ProxyFlags,
+		&rendered.APIServerFlags,
+		&rendered.ControllerManagerFlags,
+		&rendered.SchedulerFlags,
+	} {
+		buf, err := renderCompose(VersionCatalogFile, []byte(*flags), params.ClusterPorts)
+		if err != nil {
+			return nil, err
+		}
+		*flags = string(buf)
+	}
+	params.K8sVersion = &rendered
+
+	return params, nil
+}
+
+// stringSlice is flag.Value which can be specified multiple times.
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// configPaths returns compose file paths to use. Paths from flag
+// have priority over EnvConfig. If nothing is specified, it returns
+// empty and embedded k8s.yml should be used.
+func configPaths(flagPaths []string) []string {
+	if len(flagPaths) > 0 {
+		return flagPaths
+	}
+
+	env := os.Getenv(EnvConfig)
+	if env == "" {
+		return nil
+	}
+
+	paths := make([]string, 0, 1)
+	for _, path := range filepath.SplitList(env) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// loadCompose reads compose files, merges them in order and returns
+// it. If no path is given, embedded k8s.yml is used. Embedded k8s.yml
+// and files which have TemplateExt are rendered with params, others are
+// used as they are (they may have literal "{{", e.g., in env values).
+// Kubelet root directory of the cluster is set to the result regardless
+// of compose files (see injectKubeletRootDir).
+func loadCompose(paths []string, params *ComposeParams) ([]byte, error) {
+	if len(paths) == 0 {
+		compose, err := config.Asset(DefaultConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %s", DefaultConfig, err)
+		}
+
+		if compose, err = renderCompose(DefaultConfig, compose, params); err != nil {
+			return nil, err
+		}
+		return injectKubeletRootDir(compose, params)
+	}
+
+	files := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasSuffix(path, TemplateExt) {
+			if buf, err = renderCompose(path, buf, params); err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, buf)
+	}
+
+	// Only one file, no need to merge
+	if len(files) == 1 {
+		return injectKubeletRootDir(files[0], params)
+	}
+
+	merged := make(map[interface{}]interface{})
+	for i, buf := range files {
+		var services map[interface{}]interface{}
+		if err := yaml.Unmarshal(buf, &services); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %s", paths[i], err)
+		}
+
+		merged = mergeServices(merged, services)
+	}
+
+	compose, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return injectKubeletRootDir(compose, params)
+}
+
+// injectKubeletRootDir sets KubeletRootDirFlag to command of kubelet
+// (ServiceMaster) in compose and returns it. Root directory which compose
+// sets is replaced, so pod containers of the cluster are always found by
+// the one recorded in cluster state.
+func injectKubeletRootDir(compose []byte, params *ComposeParams) ([]byte, error) {
+	rootDirFlag := params.KubeletRootDirFlag()
+	if rootDirFlag == "" {
+		return compose, nil
+	}
+
+	var services map[interface{}]interface{}
+	if err := yaml.Unmarshal(compose, &services); err != nil {
+		return nil, fmt.Errorf("failed to parse compose: %s", err)
+	}
+
+	master, ok := services[ServiceMaster].(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s service (kubelet) is not found", ServiceMaster)
+	}
+
+	switch command := master["command"].(type) {
+	case string:
+		// e.g., "--root-dir=/var/lib/kubelet" or "--root-dir /var/lib/kubelet"
+		re := regexp.MustCompile(`\s+` + regexp.QuoteMeta(params.RootDirFlag) + `(=|\s+)\S+`)
+		master["command"] = re.ReplaceAllString(command, "") + " " + rootDirFlag
+	case []interface{}:
+		args := make([]interface{}, 0, len(command)+1)
+		for i := 0; i < len(command); i++ {
+			arg := fmt.Sprint(command[i])
+			if arg == params.RootDirFlag {
+				i++ // skip its value
+				continue
+			}
+			if strings.HasPrefix(arg, params.RootDirFlag+"=") {
+				continue
+			}
+			args = append(args, command[i])
+		}
+		master["command"] = append(args, rootDirFlag)
+	default:
+		return nil, fmt.Errorf("%s service has no command to set kubelet root directory", ServiceMaster)
+	}
+
+	return yaml.Marshal(services)
+}
+
+// renderCompose renders compose as text/template with params.
+func renderCompose(name string, compose []byte, params interface{}) ([]byte, error) {
+	tmpl, err := template.New(name).Parse(string(compose))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compose template %s: %s", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return nil, fmt.Errorf("failed to render compose template %s: %s", name, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mergeKeys are service keys whose values are merged (same as
+// docker-compose does with multiple files). Values of other keys
+// (e.g., command, entrypoint and dns) in src replace the ones in dst.
+var mergeKeys = map[string]bool{
+	"volumes":     true,
+	"ports":       true,
+	"environment": true,
+	"labels":      true,
+	"links":       true,
+}
+
+// mergeServices merges services of src into dst and returns it.
+// Service which is only in src is added as it is.
+func mergeServices(dst, src map[interface{}]interface{}) map[interface{}]interface{} {
+	for name, sv := range src {
+		s, ok := sv.(map[interface{}]interface{})
+		d, dok := dst[name].(map[interface{}]interface{})
+		if !ok || !dok {
+			dst[name] = sv
+			continue
+		}
+
+		dst[name] = mergeService(d, s)
+	}
+
+	return dst
+}
+
+// mergeService merges service src into dst and returns it. Maps and
+// lists of mergeKeys are merged (lists are appended without
+// duplication), and other values in src replace the ones in dst.
+func mergeService(dst, src map[interface{}]interface{}) map[interface{}]interface{} {
+	for k, sv := range src {
+		dv, ok := dst[k]
+		if !ok || !mergeKeys[fmt.Sprint(k)] {
+			dst[k] = sv
+			continue
+		}
+
+		switch s := sv.(type) {
+		case map[interface{}]interface{}:
+			if d, ok := dv.(map[interface{}]interface{}); ok {
+				for mk, mv := range s {
+					d[mk] = mv
+				}
+				continue
+			}
+		case []interface{}:
+			if d, ok := dv.([]interface{}); ok {
+				dst[k] = appendUniq(d, s)
+				continue
+			}
+		}
+
+		dst[k] = sv
+	}
+
+	return dst
+}
+
+func appendUniq(dst, src []interface{}) []interface{} {
+	for _, sv := range src {
+		found := false
+		for _, dv := range dst {
+			if fmt.Sprint(dv) == fmt.Sprint(sv) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			dst = append(dst, sv)
+		}
+	}
+	return dst
+}
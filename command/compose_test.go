@@ -0,0 +1,309 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfigPaths(t *testing.T) {
+	defer os.Setenv(EnvConfig, os.Getenv(EnvConfig))
+
+	os.Setenv(EnvConfig, "a.yml"+string(os.PathListSeparator)+"b.yml")
+	if got, want := configPaths(nil), []string{"a.yml", "b.yml"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expect %v to eq %v", got, want)
+	}
+
+	if got, want := configPaths([]string{"c.yml"}), []string{"c.yml"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expect %v to eq %v", got, want)
+	}
+
+	os.Setenv(EnvConfig, "")
+	if got := configPaths(nil); len(got) != 0 {
+		t.Fatalf("expect %v to be empty", got)
+	}
+}
+
+func TestLoadCompose_merge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := filepath.Join(dir, "base.yml")
+	ioutil.WriteFile(base, []byte(`
+etcd:
+  image: etcd:2.0.9
+  volumes:
+    - /a:/a
+  environment:
+    A: a
+master:
+  image: hyperkube:v0.21.2
+proxy:
+  command: ["/hyperkube", "proxy", "--v=2"]
+`), 0644)
+
+	override := filepath.Join(dir, "override.yml")
+	ioutil.WriteFile(override, []byte(`
+etcd:
+  image: etcd:2.0.12
+  volumes:
+    - /a:/a
+    - /b:/b
+  environment:
+    B: b
+proxy:
+  command: ["/hyperkube", "proxy", "--v=4"]
+`), 0644)
+
+	buf, err := loadCompose([]string{base, override}, &ComposeParams{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var got map[string]map[string]interface{}
+	if err := yaml.Unmarshal(buf, &got); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got["etcd"]["image"] != "etcd:2.0.12" {
+		t.Fatalf("expect etcd image to be overridden: %v", got["etcd"]["image"])
+	}
+
+	if vols := got["etcd"]["volumes"].([]interface{}); len(vols) != 2 {
+		t.Fatalf("expect volumes to be merged: %v", vols)
+	}
+
+	if env := got["etcd"]["environment"].(map[interface{}]interface{}); len(env) != 2 {
+		t.Fatalf("expect environment to be merged: %v", env)
+	}
+
+	if got["master"]["image"] != "hyperkube:v0.21.2" {
+		t.Fatalf("expect master to be kept: %v", got["master"])
+	}
+
+	// List-form command is replaced, not appended
+	cmd := got["proxy"]["command"].([]interface{})
+	if len(cmd) != 3 || cmd[2] != "--v=4" {
+		t.Fatalf("expect command to be overridden: %v", cmd)
+	}
+}
+
+func TestLoadCompose_template(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Only files which have TemplateExt are rendered
+	plain := filepath.Join(dir, "plain.yml")
+	ioutil.WriteFile(plain, []byte(`
+master:
+  environment:
+    FORMAT: "{{.Name}}"
+`), 0644)
+
+	tmpl := filepath.Join(dir, "image.yml.tmpl")
+	ioutil.WriteFile(tmpl, []byte(`
+master:
+  image: {{.Hyperkube}}
+`), 0644)
+
+	params := &ComposeParams{K8sVersion: &K8sVersion{Hyperkube: "hyperkube:v1.0.1"}, Name: "dev"}
+	buf, err := loadCompose([]string{plain, tmpl}, params)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var got map[string]map[string]interface{}
+	if err := yaml.Unmarshal(buf, &got); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got["master"]["image"] != "hyperkube:v1.0.1" {
+		t.Fatalf("expect image to be rendered: %v", got["master"]["image"])
+	}
+
+	env := got["master"]["environment"].(map[interface{}]interface{})
+	if env["FORMAT"] != "{{.Name}}" {
+		t.Fatalf("expect env to be kept as it is: %v", env["FORMAT"])
+	}
+}
+
+func TestLoadCompose_render(t *testing.T) {
+	params := &ComposeParams{
+		K8sVersion: &K8sVersion{
+			Etcd:      "etcd:2.0.12",
+			Hyperkube: "hyperkube:v1.0.1",
+		},
+		DataDir: "/data/etcd",
+	}
+
+	buf, err := loadCompose(nil, params)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var got map[string]map[string]interface{}
+	if err := yaml.Unmarshal(buf, &got); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got["master"]["image"] != "hyperkube:v1.0.1" {
+		t.Fatalf("expect image to be rendered: %v", got["master"]["image"])
+	}
+
+	vols := got["etcd"]["volumes"].([]interface{})
+	if len(vols) != 1 || vols[0] != "/data/etcd:/var/etcd/data" {
+		t.Fatalf("expect data dir to be mounted: %v", vols)
+	}
+
+	// Without data dir, no volume is declared (anonymous volume is
+	// not removed by destroy)
+	params.DataDir = ""
+	buf, err = loadCompose(nil, params)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	got = nil
+	if err := yaml.Unmarshal(buf, &got); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, ok := got["etcd"]["volumes"]; ok {
+		t.Fatalf("expect no volume without data dir: %v", got["etcd"]["volumes"])
+	}
+
+	if got["etcd"]["command"] == nil {
+		t.Fatalf("expect etcd command to be kept: %v", got["etcd"])
+	}
+}
+
+func TestNewComposeParams(t *testing.T) {
+	params, err := newComposeParams("dev", "1.0.3", 1)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Flags of the catalog are rendered with ports of the cluster
+	if !strings.Contains(params.APIServerFlags, "--insecure-port=8180") ||
+		!strings.Contains(params.KubeletFlags, "--api-servers=http://localhost:8180") {
+		t.Fatalf("expect flags to have ports of the cluster: %#v", params.K8sVersion)
+	}
+
+	compose, err := loadCompose(nil, params)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.Contains(string(compose), "--bind-addr=0.0.0.0:4101") {
+		t.Fatalf("expect etcd to listen on port of the cluster: %s", compose)
+	}
+}
+
+func TestComposeParams_KubeletRootDirFlag(t *testing.T) {
+	cases := []struct {
+		rootDirFlag string
+		expect      string
+	}{
+		{"--root_dir", "--root_dir=/var/lib/boot2k8s/dev/kubelet"},
+		{"--root-dir", "--root-dir=/var/lib/boot2k8s/dev/kubelet"},
+		{"", ""},
+	}
+
+	for i, tc := range cases {
+		params := &ComposeParams{
+			K8sVersion:     &K8sVersion{RootDirFlag: tc.rootDirFlag},
+			KubeletRootDir: kubeletRootDir("dev"),
+		}
+
+		if got := params.KubeletRootDirFlag(); got != tc.expect {
+			t.Fatalf("#%d expect %q to eq %q", i, got, tc.expect)
+		}
+	}
+}
+
+func TestLoadCompose_kubeletRootDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		compose string
+		expect  interface{}
+	}{
+		// Root directory which compose sets is replaced
+		{
+			"master:\n  command: /hyperkube kubelet --root-dir=/var/lib/kubelet --v=2\n",
+			"/hyperkube kubelet --v=2 --root-dir=/var/lib/boot2k8s/dev/kubelet",
+		},
+		{
+			"master:\n  command: /hyperkube kubelet --root-dir /var/lib/kubelet\n",
+			"/hyperkube kubelet --root-dir=/var/lib/boot2k8s/dev/kubelet",
+		},
+		{
+			"master:\n  command: [/hyperkube, kubelet, --root-dir, /var/lib/kubelet, --v=2]\n",
+			[]interface{}{"/hyperkube", "kubelet", "--v=2", "--root-dir=/var/lib/boot2k8s/dev/kubelet"},
+		},
+		// Compose which doesn't set it
+		{
+			"master:\n  command: /hyperkube kubelet\n",
+			"/hyperkube kubelet --root-dir=/var/lib/boot2k8s/dev/kubelet",
+		},
+	}
+
+	for i, tc := range cases {
+		path := filepath.Join(dir, "k8s.yml")
+		ioutil.WriteFile(path, []byte(tc.compose), 0644)
+
+		params := &ComposeParams{
+			K8sVersion:     &K8sVersion{RootDirFlag: "--root-dir"},
+			KubeletRootDir: kubeletRootDir("dev"),
+		}
+
+		compose, err := loadCompose([]string{path}, params)
+		if err != nil {
+			t.Fatalf("#%d err: %s", i, err)
+		}
+
+		var services map[string]map[string]interface{}
+		if err := yaml.Unmarshal(compose, &services); err != nil {
+			t.Fatalf("#%d err: %s", i, err)
+		}
+
+		if got := services["master"]["command"]; !reflect.DeepEqual(got, tc.expect) {
+			t.Fatalf("#%d expect %#v to eq %#v", i, got, tc.expect)
+		}
+	}
+
+	// Embedded k8s.yml sets it only once
+	params := &ComposeParams{
+		K8sVersion:     &K8sVersion{RootDirFlag: "--root-dir"},
+		KubeletRootDir: kubeletRootDir("dev"),
+	}
+	compose, err := loadCompose(nil, params)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := strings.Count(string(compose), "--root-dir="); n != 1 {
+		t.Fatalf("expect %d to eq 1: %s", n, compose)
+	}
+
+	// Kubelet must be in compose
+	path := filepath.Join(dir, "k8s.yml")
+	ioutil.WriteFile(path, []byte("etcd:\n  image: etcd\n"), 0644)
+	if _, err := loadCompose([]string{path}, params); err == nil {
+		t.Fatal("expect compose without master to fail")
+	}
+}
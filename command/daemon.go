@@ -0,0 +1,182 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kardianos/osext"
+)
+
+const (
+	// EnvForwardDaemon is set when forward command runs as daemon
+	// which is started by "forward -detach".
+	EnvForwardDaemon = "BOOT2K8S_FORWARD_DAEMON"
+
+	// Files of the forward daemon under the cluster directory.
+	ForwardPidFileName = "forward.pid"
+	ForwardLogFileName = "forward.log"
+
+	// ForwardDaemonTimeout is time to wait daemon starts listening
+	// (or stops after it's requested).
+	ForwardDaemonTimeout = 30 * time.Second
+)
+
+// forwardDaemonFiles returns path of pidfile and log file of the daemon
+// for the named cluster.
+func forwardDaemonFiles(name string) (string, string, error) {
+	dir, err := clusterDir(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	return filepath.Join(dir, ForwardPidFileName),
+		filepath.Join(dir, ForwardLogFileName), nil
+}
+
+// writePidFile writes pid of the current process to path.
+func writePidFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// readPidFile reads pid from path. If pidfile doesn't exist or its
+// process is not running, it returns 0.
+func readPidFile(path string) (int, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid pidfile %s: %s", path, err)
+	}
+
+	if !processAlive(pid) {
+		return 0, nil
+	}
+
+	return pid, nil
+}
+
+// forwardDaemonPid returns pid of the running forward daemon of the
+// named cluster. If it's not running, it returns 0.
+func forwardDaemonPid(name string) (int, error) {
+	pidPath, _, err := forwardDaemonFiles(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return readPidFile(pidPath)
+}
+
+// startForwardDaemon starts forward command with args as daemon of the
+// named cluster. It returns pid of the daemon after it starts listening.
+func startForwardDaemon(name string, args []string) (int, error) {
+	pidPath, logPath, err := forwardDaemonFiles(name)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := readPidFile(pidPath)
+	if err != nil {
+		return 0, err
+	}
+
+	if pid != 0 {
+		return 0, fmt.Errorf("port forwarding server is already running (pid %d)", pid)
+	}
+
+	// Remove stale pidfile, daemon writes it when it starts listening
+	if err := os.Remove(pidPath); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return 0, err
+	}
+
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer logFile.Close()
+
+	execPath, err := osext.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command(execPath, append([]string{"forward"}, args...)...)
+	cmd.Env = append(os.Environ(), EnvForwardDaemon+"=1")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachedProcAttr()
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	exitCh := make(chan error, 1)
+	go func() {
+		exitCh <- cmd.Wait()
+	}()
+
+	timeout := time.After(ForwardDaemonTimeout)
+	for {
+		select {
+		case err := <-exitCh:
+			return 0, fmt.Errorf("port forwarding server exited (%v), see %s", err, logPath)
+		case <-timeout:
+			cmd.Process.Kill()
+			return 0, fmt.Errorf("timeout while waiting port forwarding server starts, see %s", logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		// Pid may be different from cmd's one (panicwrap runs child process)
+		pid, err := readPidFile(pidPath)
+		if err != nil {
+			return 0, err
+		}
+
+		if pid != 0 {
+			return pid, nil
+		}
+	}
+}
+
+// stopForwardDaemon stops the running forward daemon of the named
+// cluster and returns its pid. If it's not running, it returns 0.
+func stopForwardDaemon(name string) (int, error) {
+	pid, err := forwardDaemonPid(name)
+	if err != nil || pid == 0 {
+		return 0, err
+	}
+
+	if err := terminateProcess(pid); err != nil {
+		return 0, fmt.Errorf("failed to stop process %d: %s", pid, err)
+	}
+
+	timeout := time.After(ForwardDaemonTimeout)
+	for processAlive(pid) {
+		select {
+		case <-timeout:
+			return 0, fmt.Errorf("timeout while waiting process %d stops", pid)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	return pid, nil
+}
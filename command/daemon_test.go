@@ -0,0 +1,77 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+func TestPidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "forward.pid")
+	if pid, err := readPidFile(path); err != nil || pid != 0 {
+		t.Fatalf("expect no process: %d, %v", pid, err)
+	}
+
+	if err := writePidFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	pid, err := readPidFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pid != os.Getpid() {
+		t.Fatalf("expect %d to eq %d", pid, os.Getpid())
+	}
+
+	ioutil.WriteFile(path, []byte("foo\n"), 0644)
+	if _, err := readPidFile(path); err == nil {
+		t.Fatal("expect invalid pidfile to fail")
+	}
+}
+
+func TestForwardDaemonFiles(t *testing.T) {
+	home, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	pidPath, logPath, err := forwardDaemonFiles("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(home, StateDirName, "clusters", "dev")
+	if pidPath != filepath.Join(dir, ForwardPidFileName) || logPath != filepath.Join(dir, ForwardLogFileName) {
+		t.Fatalf("expect files under %s: %s, %s", dir, pidPath, logPath)
+	}
+
+	// Daemon of other cluster is not seen
+	if err := writePidFile(pidPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if pid, err := forwardDaemonPid("prod"); err != nil || pid != 0 {
+		t.Fatalf("expect no daemon of other cluster: %d, %v", pid, err)
+	}
+
+	if pid, err := forwardDaemonPid("dev"); err != nil || pid != os.Getpid() {
+		t.Fatalf("expect %d to eq %d: %v", pid, os.Getpid(), err)
+	}
+}
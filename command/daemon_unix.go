@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package command
+
+import (
+	"syscall"
+)
+
+// detachedProcAttr returns attributes to start process in new session,
+// so it's not killed with the terminal.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive returns true if process of pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// terminateProcess asks process of pid to stop (SIGTERM).
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
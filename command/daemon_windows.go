@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package command
+
+import (
+	"os"
+	"syscall"
+)
+
+// detachedProcAttr returns attributes to start process without
+// console, so it's not killed with the terminal.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{HideWindow: true}
+}
+
+// processAlive returns true if process of pid exists.
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	proc.Release()
+	return true
+}
+
+// terminateProcess stops process of pid. Windows has no SIGTERM,
+// so it's killed.
+func terminateProcess(pid int) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return proc.Kill()
+}
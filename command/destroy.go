@@ -1,26 +1,18 @@
 package command
 
 import (
-	"bufio"
-	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
-	"io"
 	"os"
-	"os/signal"
 	"strings"
 	"sync"
 
 	"github.com/docker/libcompose/docker"
-	"github.com/docker/libcompose/project"
+	"github.com/mitchellh/cli"
 	"github.com/samalba/dockerclient"
-	"github.com/tcnksm/boot2kubernetes/config"
+	"golang.org/x/term"
 )
 
-var FilterLocalMaster = map[string][]string{
-	"label": []string{"io.kubernetes.pod.name=default/k8s-master-127.0.0.1"},
-}
-
 var FilterK8SRelated = map[string][]string{
 	"label": []string{"io.kubernetes.pod.name"},
 }
@@ -31,29 +23,36 @@ type DestroyCommand struct {
 
 func (c *DestroyCommand) Run(args []string) int {
 
-	var insecure bool
-	flags := flag.NewFlagSet("destroy", flag.ContinueOnError)
+	var insecure, keepData, force, onlyCluster bool
+	var configs stringSlice
+	flags := c.Meta.flagSet("destroy")
 	flags.BoolVar(&insecure, "insecure", false, "")
+	flags.Var(&configs, "config", "")
+	flags.BoolVar(&keepData, "keep-data", false, "")
+	flags.BoolVar(&force, "force", false, "")
+	flags.BoolVar(&force, "yes", false, "")
+	flags.BoolVar(&onlyCluster, "only-cluster", false, "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 
-	errR, errW := io.Pipe()
-	errScanner := bufio.NewScanner(errR)
-	go func() {
-		for errScanner.Scan() {
-			c.Ui.Error(errScanner.Text())
-		}
-	}()
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
 
-	flags.SetOutput(errW)
+	name := c.clusterName()
 
-	if err := flags.Parse(args); err != nil {
+	// Kubelet root directory identifies pod containers of the cluster.
+	// State is removed below, so read it first.
+	state, err := LoadClusterState(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
 		return 1
 	}
 
-	compose, err := config.Asset("k8s.yml")
+	compose, err := clusterCompose(name, configs)
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
-			"Failed to read k8s.yml: %s", err))
+			"Failed to read compose file: %s", err))
 		return 1
 	}
 
@@ -70,95 +69,94 @@ func (c *DestroyCommand) Run(args []string) int {
 	}
 
 	// Setup new docker-compose project
-	context := &docker.Context{
-		Context: project.Context{
-			Log:          false,
-			ComposeBytes: compose,
-			ProjectName:  "boot2k8s",
-		},
-		ClientFactory: clientFactory,
-	}
-
-	project, err := docker.NewProject(context)
+	project, err := newProject(name, compose, clientFactory)
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Failed to setup project: %s", err))
 		return 1
 	}
 
-	if err := project.Delete(); err != nil {
-		c.Ui.Error(fmt.Sprintf(
-			"Failed to destroy project: %s", err))
-		return 1
-	}
+	if keepData {
+		// Keep etcd container (and its data volume). It's started
+		// again by next up.
+		if err := project.Down(ServiceEtcd); err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to stop etcd: %s", err))
+			return 1
+		}
 
-	client := clientFactory.Create(nil)
+		if err := project.Delete(ServiceAPIServer, ServiceControllerManager, ServiceScheduler, ServiceMaster, ServiceProxy); err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to destroy project: %s", err))
+			return 1
+		}
+	} else {
+		if err := project.Delete(); err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to destroy project: %s", err))
+			return 1
+		}
+
+		if err := RemoveClusterState(name); err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to remove cluster state: %s", err))
+			return 1
+		}
+	}
 
-	// Marshaling to post filter as API request
-	filterLocalMasterStr, _ := json.Marshal(FilterLocalMaster)
-	// Get Container info from daemon based on filter
-	localMasters, err := client.ListContainers(true, false, (string)(filterLocalMasterStr))
+	// Port forwarding server is useless without cluster
+	pid, err := stopForwardDaemon(name)
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
-			"Failed to list containers: %s", err))
+			"Failed to stop port forwarding server: %s", err))
 		return 1
 	}
 
-	if len(localMasters) > 0 {
-		c.Ui.Output("Are you sure you want to destroy below containers?")
-		for _, container := range localMasters {
-			c.Ui.Output(fmt.Sprintf("  %s", container.Names[0]))
-		}
+	if pid != 0 {
+		c.Ui.Output(fmt.Sprintf("Successfully stop port forwarding server (pid %d)", pid))
+	}
 
-		if yes, err := AskYesNo(); !yes || err != nil {
-			if err == nil {
-				c.Ui.Info("Containers will no be destroyed, since the confirmation")
-				return 0
-			}
-			c.Ui.Error(fmt.Sprintf(
-				"Terminate to destroy: %s", err.Error()))
-			return 1
-		}
+	if err := removeKubeconfig(name); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to remove kubeconfig entry: %s", err))
+		return 1
+	}
 
-		resultCh, errCh := removeContainers(client, localMasters, true, true)
-		go func() {
-			for res := range resultCh {
-				c.Ui.Output(fmt.Sprintf(
-					"Successfully destroy %s", res.Names[0]))
-			}
-		}()
+	if onlyCluster {
+		return 0
+	}
 
-		for err := range errCh {
-			c.Ui.Error(fmt.Sprintf("Error: %s", err))
-		}
-		c.Ui.Output("")
+	rootDir := podRootDir(c.Ui, name, state)
+	if rootDir == "" {
+		return 0
 	}
 
-	// Marshaling to post filter as API request
-	filterK8SRelatedStr, _ := json.Marshal(FilterK8SRelated)
-	relatedContainers, err := client.ListContainers(true, false, (string)(filterK8SRelatedStr))
+	client := clientFactory.Create(nil)
+	pods, err := listPodContainers(client, rootDir)
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Failed to list containers: %s", err))
 		return 1
 	}
 
-	if len(relatedContainers) < 1 {
-		// Correctly clean all containers
+	if len(pods) < 1 {
 		return 0
 	}
 
-	c.Ui.Output("Do you also remove these containers? (these are created by kubernetes)")
-	c.Ui.Error("==> WARNING: boot2kubernetes can not detect below containers")
-	c.Ui.Error("  are created by kubernetes which up by boot2kubernetes.")
-	c.Ui.Error("  Be sure below these will not be used anymore!")
-	for _, container := range relatedContainers {
+	c.Ui.Output("Do you also remove these containers? (these are created by kubernetes of the cluster)")
+	for _, container := range pods {
 		c.Ui.Output(fmt.Sprintf("  %s", container.Names[0]))
 	}
 
-	if yes, err := AskYesNo(); !yes || err != nil {
+	return c.confirmRemove(client, pods, force)
+}
+
+// confirmRemove removes containers after confirmation and returns
+// exit code.
+func (c *DestroyCommand) confirmRemove(client dockerclient.Client, containers []dockerclient.Container, force bool) int {
+	if yes, err := confirm(c.Ui, force); !yes || err != nil {
 		if err == nil {
-			c.Ui.Info("Containers will no be destroyed, since the confirmation")
+			c.Ui.Info("Containers will not be destroyed, since the confirmation")
 			return 0
 		}
 		c.Ui.Error(fmt.Sprintf(
@@ -166,7 +164,7 @@ func (c *DestroyCommand) Run(args []string) int {
 		return 1
 	}
 
-	resultCh, errCh := removeContainers(client, relatedContainers, true, true)
+	resultCh, errCh := removeContainers(client, containers, true, true)
 	go func() {
 		for res := range resultCh {
 			c.Ui.Output(fmt.Sprintf(
@@ -174,11 +172,13 @@ func (c *DestroyCommand) Run(args []string) int {
 		}
 	}()
 
+	exitCode := 0
 	for err := range errCh {
 		c.Ui.Error(fmt.Sprintf("Error: %s", err))
+		exitCode = 1
 	}
 
-	return 0
+	return exitCode
 }
 
 func (c *DestroyCommand) Synopsis() string {
@@ -186,11 +186,34 @@ func (c *DestroyCommand) Synopsis() string {
 }
 
 func (c *DestroyCommand) Help() string {
-	helpText := `Destroy kubernetes cluster.
+	helpText := `Destroy kubernetes cluster. Containers which are created by
+kubelet of the cluster (pod containers) are also destroyed after
+confirmation. They are identified by kubelet root directory which is
+recorded at up. If it's not recorded (the cluster is created by older
+boot2k8s), pod containers are kept with warning.
 
 Options:
 
-  -insecure    Allow insecure non-TLS connection to docker client. 
+  -name=NAME      Name of the cluster to destroy. Default is "default".
+
+  -config=PATH    Compose file to use instead of embedded k8s.yml.
+                  Can be specified multiple times, files are merged
+                  in order. It can be also set via BOOT2K8S_CONFIG
+                  (multiple files are separated by ':').
+
+  -insecure       Allow insecure non-TLS connection to docker client.
+
+  -keep-data      Keep etcd container and its data, so next up starts
+                  cluster with the same objects. Data in the directory
+                  given by "up -data-dir" is always kept.
+
+  -only-cluster   Destroy only containers of the cluster (etcd, API
+                  server, master and so on). Containers created by
+                  kubernetes are kept without confirmation.
+
+  -force, -yes    Destroy containers created by kubernetes without
+                  confirmation. Without it, confirmation is asked and
+                  destroy fails if stdin is not a terminal (e.g., in CI).
 `
 	return strings.TrimSpace(helpText)
 }
@@ -224,37 +247,45 @@ func removeContainers(client dockerclient.Client, containers []dockerclient.Cont
 	return resultCh, errCh
 }
 
-func AskYesNo() (bool, error) {
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
-	defer signal.Stop(sigCh)
+// errNotTerminal is returned when confirmation is needed but stdin
+// is not a terminal (e.g., in CI).
+var errNotTerminal = errors.New(
+	"stdin is not a terminal, so confirmation can not be asked (use -force to skip it)")
 
-	ansCh := make(chan bool, 1)
-	go func() {
-		for {
-			fmt.Fprintf(os.Stderr, "Your choice? (Y/n) [default: n]: ")
+// stdinIsTerminal returns true if stdin is a terminal. It's variable,
+// so it can be replaced in tests.
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
 
-			reader := bufio.NewReader(os.Stdin)
-			line, _ := reader.ReadString('\n')
-			line = strings.TrimRight(line, "\n")
+// confirm asks confirmation via ui unless force is true. If stdin is
+// not a terminal, answer is regarded as "no" and errNotTerminal is returned.
+func confirm(ui cli.Ui, force bool) (bool, error) {
+	if force {
+		return true, nil
+	}
 
-			// Use Default value
-			if line == "Y" {
-				ansCh <- true
-				break
-			}
+	if !stdinIsTerminal() {
+		return false, errNotTerminal
+	}
 
-			if line == "n" || line == "" {
-				ansCh <- false
-				break
-			}
+	return AskYesNo(ui)
+}
+
+// AskYesNo asks yes or no via ui until it gets valid answer.
+// Default (empty answer) is no.
+func AskYesNo(ui cli.Ui) (bool, error) {
+	for {
+		line, err := ui.Ask("Your choice? (Y/n) [default: n]:")
+		if err != nil {
+			return false, err
 		}
-	}()
 
-	select {
-	case <-sigCh:
-		return false, fmt.Errorf("interrupted")
-	case yes := <-ansCh:
-		return yes, nil
+		switch strings.TrimSpace(line) {
+		case "Y", "y", "yes":
+			return true, nil
+		case "n", "N", "no", "":
+			return false, nil
+		}
 	}
 }
@@ -9,3 +9,58 @@ import (
 func TestDestroyCommand_implement(t *testing.T) {
 	var _ cli.Command = &DestroyCommand{}
 }
+
+// answerUi answers to Ask in order.
+type answerUi struct {
+	cli.Ui
+	answers []string
+}
+
+func (u *answerUi) Ask(query string) (string, error) {
+	answer := u.answers[0]
+	u.answers = u.answers[1:]
+	return answer, nil
+}
+
+func TestAskYesNo(t *testing.T) {
+	cases := []struct {
+		answers []string
+		expect  bool
+	}{
+		{[]string{"Y"}, true},
+		{[]string{"yes"}, true},
+		{[]string{"n"}, false},
+		{[]string{""}, false},
+		{[]string{"maybe", "Y"}, true},
+	}
+
+	for i, tc := range cases {
+		yes, err := AskYesNo(&answerUi{answers: tc.answers})
+		if err != nil {
+			t.Fatalf("#%d err: %s", i, err)
+		}
+
+		if yes != tc.expect {
+			t.Fatalf("#%d expect %t to eq %t", i, yes, tc.expect)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	defer func(f func() bool) { stdinIsTerminal = f }(stdinIsTerminal)
+
+	// Answer is not asked when stdin is not a terminal
+	stdinIsTerminal = func() bool { return false }
+	if yes, err := confirm(&answerUi{}, false); yes || err != errNotTerminal {
+		t.Fatalf("expect no and errNotTerminal: %t, %v", yes, err)
+	}
+
+	if yes, err := confirm(&answerUi{}, true); !yes || err != nil {
+		t.Fatalf("expect yes without error when forced: %t, %v", yes, err)
+	}
+
+	stdinIsTerminal = func() bool { return true }
+	if yes, err := confirm(&answerUi{answers: []string{"Y"}}, false); !yes || err != nil {
+		t.Fatalf("expect yes: %t, %v", yes, err)
+	}
+}
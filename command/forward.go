@@ -1,41 +1,104 @@
 package command
 
 import (
-	"bufio"
-	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/hashicorp/logutils"
-	"github.com/mitchellh/go-homedir"
+	"github.com/mitchellh/cli"
 	"golang.org/x/crypto/ssh"
 )
 
 const (
-	// Boot2docker related constants.
-	// These constants are from github.com/boot2docker/boot2docker-cli
-	B2DSshKeyFile string = "id_boot2docker"
-	B2DSshServer  string = "localhost:2022"
-	B2DSshUser    string = "docker"
-
 	// ClosingTime is time to wait until all server is closing
 	ClosingTime = 1 * time.Second
 )
 
-var (
-	DefaultLocalServer  = "localhost:8080"
-	DefaultRemoteServer = "localhost:8080"
+// Values of -forward flag which decides whether port forwarding runs.
+const (
+	// ForwardAuto runs port forwarding when docker runs on remote host
+	// (e.g., boot2docker VM) and API server is not reachable on localhost.
+	ForwardAuto   = "auto"
+	ForwardAlways = "always"
+	ForwardNever  = "never"
 )
 
+// dockerIsLocal returns true if docker daemon of the given endpoint
+// (DOCKER_HOST) runs on this host.
+func dockerIsLocal(endpoint string) bool {
+	if endpoint == "" {
+		return true
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "unix", "npipe":
+		return true
+	case "tcp":
+		host, _, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			host = u.Host
+		}
+
+		if host == "localhost" {
+			return true
+		}
+
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	default:
+		return false
+	}
+}
+
+// validateForwardMode checks -forward value.
+func validateForwardMode(mode string) error {
+	switch mode {
+	case ForwardAuto, ForwardAlways, ForwardNever:
+		return nil
+	default:
+		return fmt.Errorf("Invalid -forward %q: must be auto, always or never", mode)
+	}
+}
+
+// needForward decides whether port forwarding is needed by -forward value
+// for the cluster whose API server listens on ports.
+func needForward(mode string, ports ClusterPorts) (bool, error) {
+	if err := validateForwardMode(mode); err != nil {
+		return false, err
+	}
+
+	switch mode {
+	case ForwardAlways:
+		return true, nil
+	case ForwardNever:
+		return false, nil
+	case ForwardAuto:
+		if dockerIsLocal(os.Getenv("DOCKER_HOST")) {
+			return false, nil
+		}
+
+		// e.g., another port forwarding server is running
+		_, err := httpGet(ports.LocalServer(), "/healthz")
+		return err != nil, nil
+	default:
+		return false, nil
+	}
+}
+
 type ForwardCommand struct {
 	Meta
 }
@@ -43,44 +106,88 @@ type ForwardCommand struct {
 func (c *ForwardCommand) Run(args []string) int {
 
 	var logLevel string
-	flags := flag.NewFlagSet("forward", flag.ContinueOnError)
+	var mappings portMappings
+	var sshFlags SSHConfig
+	var watchServices, detach bool
+	var forwardMode, socksAddr, statsAddr string
+	flags := c.Meta.flagSet("forward")
 	flags.StringVar(&logLevel, "log-level", "info", "")
+	flags.StringVar(&forwardMode, "forward", ForwardAuto, "")
+	flags.StringVar(&socksAddr, "socks", "", "")
+	flags.StringVar(&statsAddr, "stats-addr", "", "")
+	flags.BoolVar(&detach, "detach", false, "")
+	flags.Var(&mappings, "L", "")
+	flags.BoolVar(&watchServices, "watch-services", true, "")
+	addSSHFlags(flags, &sshFlags)
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 
-	errR, errW := io.Pipe()
-	errScanner := bufio.NewScanner(errR)
-	go func() {
-		for errScanner.Scan() {
-			c.Ui.Error(errScanner.Text())
-		}
-	}()
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
 
-	flags.SetOutput(errW)
+	// Each cluster has its own API server port
+	ports, err := loadClusterPorts(c.clusterName())
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
+		return 1
+	}
 
-	if err := flags.Parse(args); err != nil {
+	// Proxy forwarding is only needed when docker runs on VM
+	// (e.g., boot2docker).
+	forward, err := needForward(forwardMode, ports)
+	if err != nil {
+		c.Ui.Error(err.Error())
 		return 1
 	}
 
-	// Proxy forwarding is only for OS which need boot2docker
-	// to run docker.
-	if runtime.GOOS != "darwin" {
-		c.Ui.Error("You don't need to run port forwarding")
+	if !forward {
+		c.Ui.Error("You don't need to run port forwarding (docker runs locally or")
+		c.Ui.Error("API server is reachable on localhost). To run it anyway, use -forward=always")
+		return 0
+	}
+
+	if detach {
+		pid, err := startForwardDaemon(c.clusterName(),
+			forwardArgs(c.Meta.Name, logLevel, mappings, sshFlags, watchServices, socksAddr, statsAddr))
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to start port forwarding server: %s", err))
+			return 1
+		}
+
+		outputForwardDaemon(c.Ui, c.clusterName(), pid)
 		return 0
 	}
 
 	// Create logger with Log level
-	logger := log.New(&logutils.LevelFilter{
-		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERROR"},
-		MinLevel: (logutils.LogLevel)(strings.ToUpper(logLevel)),
-		Writer:   os.Stderr,
-	}, "", log.LstdFlags)
-	logger.Printf("[DEBUG] LogLevel: %s", logLevel)
+	logger := newLogger(logLevel)
+
+	sshConfig, err := resolveSSHConfig(sshFlags)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read SSH settings: %s", err))
+		return 1
+	}
+
+	auth, err := sshConfig.AuthMethods(c.Ui)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to construct SSH auth method: %s", err))
+		return 1
+	}
+	defer sshConfig.Close()
 
 	// Setup port forward server
 	server := &PortForwardServer{
-		Logger:       logger,
-		LocalServer:  DefaultLocalServer,
-		RemoteServer: DefaultRemoteServer,
+		Logger:        logger,
+		Mappings:      mappings.withDefault(ports),
+		APIServer:     ports.RemoteServer(),
+		SSH:           sshConfig,
+		Auth:          auth,
+		WatchServices: watchServices,
+		SocksAddr:     socksAddr,
+		StatsAddr:     statsAddr,
 	}
 
 	doneCh, errCh, err := server.Start()
@@ -90,8 +197,35 @@ func (c *ForwardCommand) Run(args []string) int {
 		return 1
 	}
 
+	// "forward stats -name" finds statistics of this cluster's server
+	if statsAddr != "" {
+		remove, err := writeForwardStatsAddr(c.clusterName(), statsAddr)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to record statistics address: %s", err))
+			close(doneCh)
+			return 1
+		}
+		defer remove()
+	}
+
+	// Running as daemon, pidfile tells "forward -detach" it's ready
+	if os.Getenv(EnvForwardDaemon) != "" {
+		pidPath, _, err := forwardDaemonFiles(c.clusterName())
+		if err == nil {
+			err = writePidFile(pidPath)
+		}
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to write pidfile: %s", err))
+			close(doneCh)
+			return 1
+		}
+		defer os.Remove(pidPath)
+	}
+
 	sigCh := make(chan os.Signal)
-	signal.Notify(sigCh, os.Interrupt)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	select {
 	case err := <-errCh:
 		c.Ui.Error(fmt.Sprintf(
@@ -108,55 +242,307 @@ func (c *ForwardCommand) Run(args []string) int {
 	return 0
 }
 
+// outputForwardDaemon shows how to handle the forward daemon of the
+// named cluster.
+func outputForwardDaemon(ui cli.Ui, name string, pid int) {
+	_, logPath, _ := forwardDaemonFiles(name)
+	ui.Info(fmt.Sprintf(
+		"Port forwarding server is running in background (pid %d)", pid))
+	ui.Output(fmt.Sprintf(
+		"Logs are written to %s. To stop it, use `forward stop -name=%s`", logPath, name))
+}
+
+// forwardArgs returns arguments of forward command which runs
+// port forwarding server with the given settings.
+func forwardArgs(name, logLevel string, mappings []PortMapping, sshFlags SSHConfig, watchServices bool, socksAddr, statsAddr string) []string {
+	args := []string{
+		"-name=" + name,
+		"-forward=" + ForwardAlways,
+		"-log-level=" + logLevel,
+		"-watch-services=" + strconv.FormatBool(watchServices),
+	}
+
+	for _, m := range mappings {
+		args = append(args, "-L="+m.String())
+	}
+
+	for _, f := range []struct{ name, value string }{
+		{"ssh-host", sshFlags.Host},
+		{"ssh-port", sshFlags.Port},
+		{"ssh-user", sshFlags.User},
+		{"ssh-key", sshFlags.KeyFile},
+	} {
+		if f.value != "" {
+			args = append(args, "-"+f.name+"="+f.value)
+		}
+	}
+
+	if sshFlags.InsecureSkipHostKey {
+		args = append(args, "-insecure-skip-host-key")
+	}
+
+	if socksAddr != "" {
+		args = append(args, "-socks="+socksAddr)
+	}
+
+	if statsAddr != "" {
+		args = append(args, "-stats-addr="+statsAddr)
+	}
+
+	return args
+}
+
 func (c *ForwardCommand) Synopsis() string {
 	return "Run port forwarding server"
 }
 
 func (c *ForwardCommand) Help() string {
-	helpText := `Run port forwarding server. 
+	helpText := `Run port forwarding server. It forwards local ports to ports
+on boot2docker VM over SSH connection (e.g., to connect API server by
+local kubectl).
+
+Options:
+
+  -L=MAPPING      Port mapping, [LOCAL_HOST:]LOCAL_PORT:[REMOTE_HOST:]REMOTE_PORT
+                  (same as ssh -L). Can be specified multiple times
+                  (e.g., -L 4001:4001 -L 10250:10250). API server
+                  of the cluster is always forwarded in addition to
+                  the same local port (8080:8080 for the default
+                  cluster, see "up -help").
+
+  -forward=MODE   Whether to run port forwarding, auto, always or never.
+                  With auto, it runs only when docker daemon is not on
+                  this host (DOCKER_HOST) and API server is not reachable
+                  on localhost. Default is auto.
+
+  -detach         Run port forwarding server in background. It returns
+                  once the server is listening. Pidfile and log file are
+                  written to ~/.boot2k8s/clusters/NAME/forward.pid and
+                  forward.log, so each cluster has its own server.
+                  Use "forward status" and "forward stop" to handle it.
+                  Encrypted SSH key can't be used (use ssh-agent instead).
+
+  -socks=ADDR     Run SOCKS5 proxy server on ADDR (e.g., localhost:1080).
+                  Connections via the proxy are made from docker host,
+                  so pod IPs and service IPs can be reached (e.g.,
+                  curl --socks5-hostname localhost:1080 http://10.0.0.10).
+
+  -stats-addr=ADDR
+                  Serve statistics of connections (active, total, bytes,
+                  dial latency and errors of each mapping) on ADDR
+                  (e.g., localhost:8079). JSON is served on /stats and
+                  Prometheus text format on /metrics. Use "forward stats"
+                  to print them.
+
+  -watch-services
+                  Watch Services via API server and forward their
+                  NodePorts to the same local ports (e.g., localhost:30080)
+                  while they exist. Default is true. To disable it, use
+                  -watch-services=false.
+
+  -ssh-host=HOST  Host of SSH server to tunnel through. It can be also
+                  set via BOOT2K8S_SSH_HOST. Default is localhost.
+
+  -ssh-port=PORT  Port of SSH server. It can be also set via
+                  BOOT2K8S_SSH_PORT. Default is 2022 (boot2docker).
+
+  -ssh-user=USER  User of SSH server. It can be also set via
+                  BOOT2K8S_SSH_USER. Default is docker.
+
+  -ssh-key=PATH   Private key file. It can be also set via
+                  BOOT2K8S_SSH_KEY. Default is ~/.ssh/id_boot2docker.
+                  Passphrase is asked if the key is encrypted. Keys in
+                  ssh-agent (SSH_AUTH_SOCK) are also used.
+
+  -insecure-skip-host-key
+                  Skip verification of SSH host key. By default, host
+                  key is trusted on first connection and recorded in
+                  ~/.boot2k8s/known_hosts. If the key is changed later,
+                  connection is refused.
+
+  -log-level      Log level (DEBUG, INFO, WARN, ERROR).
+                  Default is INFO.
+
+SSH settings can be also written in ~/.boot2k8s/config.yml,
+
+  ssh:
+    host: 192.168.99.100
+    port: 22
+    user: docker
+    key: ~/.docker/machine/machines/dev/id_rsa
 `
 	return strings.TrimSpace(helpText)
 }
 
-// PortforwardServer
+// PortMapping is a pair of local address to listen on and remote
+// address on docker host to forward traffic to.
+type PortMapping struct {
+	Local  string
+	Remote string
+}
+
+func (m PortMapping) String() string {
+	return m.Local + ":" + m.Remote
+}
+
+// parsePortMapping parses -L value. It's same as ssh -L format,
+// [LOCAL_HOST:]LOCAL_PORT:REMOTE_HOST:REMOTE_PORT, and REMOTE_HOST
+// can be also omitted (e.g., 4001:4001). Omitted host is localhost.
+func parsePortMapping(s string) (PortMapping, error) {
+	parts := strings.Split(s, ":")
+	for _, part := range parts {
+		if part == "" {
+			return PortMapping{}, fmt.Errorf("invalid port mapping %q", s)
+		}
+	}
+
+	var m PortMapping
+	switch len(parts) {
+	case 2:
+		m.Local = net.JoinHostPort("localhost", parts[0])
+		m.Remote = net.JoinHostPort("localhost", parts[1])
+	case 3:
+		m.Local = net.JoinHostPort("localhost", parts[0])
+		m.Remote = net.JoinHostPort(parts[1], parts[2])
+	case 4:
+		m.Local = net.JoinHostPort(parts[0], parts[1])
+		m.Remote = net.JoinHostPort(parts[2], parts[3])
+	default:
+		return PortMapping{}, fmt.Errorf(
+			"invalid port mapping %q: must be [LOCAL_HOST:]LOCAL_PORT:[REMOTE_HOST:]REMOTE_PORT", s)
+	}
+
+	// Port is always the last part of each side
+	for _, addr := range []string{m.Local, m.Remote} {
+		_, port, _ := net.SplitHostPort(addr)
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return PortMapping{}, fmt.Errorf("invalid port %q in port mapping %q", port, s)
+		}
+	}
+
+	return m, nil
+}
+
+// portMappings is flag.Value which can be specified multiple times.
+type portMappings []PortMapping
+
+func (p *portMappings) String() string {
+	strs := make([]string, 0, len(*p))
+	for _, m := range *p {
+		strs = append(strs, m.String())
+	}
+	return strings.Join(strs, ",")
+}
+
+func (p *portMappings) Set(value string) error {
+	m, err := parsePortMapping(value)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, m)
+	return nil
+}
+
+// withDefault returns the mapping to API server which listens on ports
+// followed by mappings. The API server mapping is omitted if mappings
+// already use its local address (e.g., -L 8080:8080).
+func (p portMappings) withDefault(ports ClusterPorts) []PortMapping {
+	for _, m := range p {
+		if m.Local == ports.LocalServer() {
+			return p
+		}
+	}
+	return append([]PortMapping{{Local: ports.LocalServer(), Remote: ports.RemoteServer()}}, p...)
+}
+
+// PortforwardServer forwards traffic from local addresses to remote
+// addresses on docker host. All mappings are carried over one ssh connection.
 type PortForwardServer struct {
-	Logger       *log.Logger
-	LocalServer  string
-	RemoteServer string
+	Logger   *log.Logger
+	Mappings []PortMapping
+
+	// SSH is settings of SSH server and Auth is how to authenticate with it
+	SSH  *SSHConfig
+	Auth []ssh.AuthMethod
+
+	// SocksAddr is local address of SOCKS5 proxy server. Connections
+	// via the proxy are dialed from docker host. If empty, it's disabled.
+	SocksAddr string
+
+	// APIServer is address of API server on docker host. Services
+	// are watched via it.
+	APIServer string
+
+	// WatchServices enables forwarding NodePorts of Services which
+	// are found via API server.
+	WatchServices bool
+
+	// StatsAddr is local address of HTTP server which serves statistics
+	// of connections (JSON on /stats and Prometheus on /metrics).
+	// If empty, it's disabled.
+	StatsAddr string
+
+	stats     *forwardStats
+	nodePorts map[int]*nodePortListener
 }
 
 // Start starts server
 func (s *PortForwardServer) Start() (chan struct{}, chan error, error) {
-	// Setup ssh auth method from boot2docker ssh key file
-	authMethod, err := B2DSshAuthMethod()
+	// Establish connection with SSH server. It's reconnected when
+	// it's lost while local servers keep listening.
+	sshConn, err := connectSSHTunnel(s.Logger, s.SSH, s.Auth)
 	if err != nil {
-		return nil, nil, fmt.Errorf(
-			"failed to construct ssh auth method for boot2docker: %s", err)
+		return nil, nil, err
 	}
 
-	cfg := &ssh.ClientConfig{
-		User: B2DSshUser,
-		Auth: []ssh.AuthMethod{
-			authMethod,
-		},
+	// Start local servers to forward traffic to remote servers
+	listeners := make([]net.Listener, 0, len(s.Mappings))
+	for _, m := range s.Mappings {
+		localListener, err := net.Listen("tcp", m.Local)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			sshConn.Close()
+			return nil, nil, fmt.Errorf(
+				"failed to start local server %s: %s", m.Local, err)
+		}
+		s.Logger.Printf("[INFO] Listening on %s and forwarding to %s (Ready to connection)", m.Local, m.Remote)
+		listeners = append(listeners, localListener)
 	}
 
-	// Establish connection with SSH server
-	sshConn, err := ssh.Dial("tcp", B2DSshServer, cfg)
-	if err != nil {
-		return nil, nil, fmt.Errorf(
-			"failed to establish connection with SSH server %s: %s", B2DSshServer, err)
+	var socksListener net.Listener
+	if s.SocksAddr != "" {
+		socksListener, err = net.Listen("tcp", s.SocksAddr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			sshConn.Close()
+			return nil, nil, fmt.Errorf(
+				"failed to start SOCKS server %s: %s", s.SocksAddr, err)
+		}
+		s.Logger.Printf("[INFO] Listening on %s as SOCKS5 proxy (Ready to connection)", s.SocksAddr)
 	}
-	s.Logger.Printf("[DEBUG] Establish connection with SSH server %s", B2DSshServer)
 
-	// Start local server to forward traffic to remote server
-	localListener, err := net.Listen("tcp", s.LocalServer)
-	if err != nil {
-		return nil, nil, fmt.Errorf(
-			"failed to start local server %s: %s", s.LocalServer, err)
+	s.stats = newForwardStats()
+	if s.StatsAddr != "" {
+		statsListener, err := net.Listen("tcp", s.StatsAddr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			if socksListener != nil {
+				socksListener.Close()
+			}
+			sshConn.Close()
+			return nil, nil, fmt.Errorf(
+				"failed to start stats server %s: %s", s.StatsAddr, err)
+		}
+		s.Logger.Printf("[INFO] Serving statistics on http://%s/stats and /metrics", s.StatsAddr)
+		listeners = append(listeners, statsListener)
+		go http.Serve(statsListener, s.stats.Handler())
 	}
-	s.Logger.Printf("[INFO] Start local server")
-	s.Logger.Printf("[INFO] Listening on %s (Ready to connection)", s.LocalServer)
 
 	doneCh, errCh := make(chan struct{}), make(chan error)
 
@@ -165,81 +551,206 @@ func (s *PortForwardServer) Start() (chan struct{}, chan error, error) {
 		select {
 		case <-doneCh:
 			s.Logger.Println("[INFO] Stop server and close ssh connection")
-			localListener.Close()
+			for _, l := range listeners {
+				l.Close()
+			}
+			if socksListener != nil {
+				socksListener.Close()
+			}
 			sshConn.Close()
 		}
 	}()
 
-	go func() {
-		for {
+	if socksListener != nil {
+		stats := s.stats.Mapping("socks " + s.SocksAddr)
+		go s.serve(socksListener, func(conn net.Conn) {
+			s.socks(conn, sshConn.Dial, stats)
+		}, doneCh, errCh)
+	}
 
-			// Accept request and start local connection
-			localConn, err := localListener.Accept()
-			if err != nil {
-				errCh <- fmt.Errorf("failed to accept request: %s", err)
-			}
-			s.Logger.Printf("[DEBUG] Accept request")
-
-			// Establish connection with remote server via SSH connection
-			sshRemoteConn, err := sshConn.Dial("tcp", s.RemoteServer)
-			if err != nil {
-				errCh <- fmt.Errorf(
-					"failed to establish connection with remote server on boot2docker:\n"+"%s\n"+
-						"This error happens when kubelet is not working. "+
-						"Check it via `docker ps` command.", err)
-			}
-			s.Logger.Printf("[DEBUG] Establish connection with remote server %s", s.RemoteServer)
-
-			doneRWCh := make(chan struct{})
-			go func() {
-				s.Logger.Printf("[DEBUG] Start data transfer from remote server to local")
-				_, err := io.Copy(localConn, sshRemoteConn)
-				if err != nil {
-					s.Logger.Printf(
-						"[ERROR] Failed to transfer from remote server to local: %s", err)
-				}
-				doneRWCh <- struct{}{}
-			}()
-
-			go func() {
-				s.Logger.Printf("[DEBUG] Start data transfer from local server to remote")
-				_, err := io.Copy(sshRemoteConn, localConn)
-				if err != nil {
-					s.Logger.Printf("[ERROR] Failed to transfer from local server to remote: %s", err)
-				}
-				doneRWCh <- struct{}{}
-			}()
+	for i, m := range s.Mappings {
+		remote, stats := m.Remote, s.stats.Mapping(m.String())
+		go s.serve(listeners[i], func(conn net.Conn) {
+			s.forward(conn, sshConn.Dial, remote, stats)
+		}, doneCh, errCh)
+	}
 
-			<-doneRWCh
-			localConn.Close()
-			sshRemoteConn.Close()
-			s.Logger.Printf("[DEBUG] Finish forwarding")
+	if s.WatchServices {
+		s.nodePorts = make(map[int]*nodePortListener)
+		watcher := &serviceWatcher{
+			Logger: s.Logger,
+			Client: &http.Client{
+				Transport: &http.Transport{Dial: sshConn.Dial},
+			},
+			APIServer: s.APIServer,
+			Open: func(name string, port int) error {
+				return s.openNodePort(name, port, sshConn.Dial, errCh)
+			},
+			Close: func(name string, port int) {
+				s.closeNodePort(port)
+			},
 		}
-	}()
+		go watcher.Run(doneCh)
+	}
 
 	return doneCh, errCh, nil
 }
 
-// B2DSshAuthMethod return ssh auth method for boot2docker.
-// It reads & parses ssh key file and constructs auth method.
-// If something wrong, returns error.
-func B2DSshAuthMethod() (ssh.AuthMethod, error) {
-	home, err := homedir.Dir()
-	if err != nil {
-		return nil, err
+// openNodePort starts local server which forwards port to the same
+// NodePort on docker host. Statistics are recorded by name of the service.
+func (s *PortForwardServer) openNodePort(name string, port int, dial dialFunc, errCh chan error) error {
+	if _, ok := s.nodePorts[port]; ok {
+		return fmt.Errorf("port %d is already forwarded", port)
 	}
 
-	// Read default file
-	keyPath := filepath.Join(home, ".ssh", B2DSshKeyFile)
-	buff, err := ioutil.ReadFile(keyPath)
+	addr := net.JoinHostPort("localhost", strconv.Itoa(port))
+	l, err := net.Listen("tcp", addr)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	np := &nodePortListener{Listener: l, stopCh: make(chan struct{})}
+	s.nodePorts[port] = np
+	stats := s.stats.Mapping(name)
+	go s.serve(np, func(conn net.Conn) {
+		s.forward(conn, dial, addr, stats)
+	}, np.stopCh, errCh)
+	return nil
+}
+
+// closeNodePort stops local server of the NodePort.
+func (s *PortForwardServer) closeNodePort(port int) {
+	np, ok := s.nodePorts[port]
+	if !ok {
+		return
+	}
+
+	delete(s.nodePorts, port)
+	close(np.stopCh)
+	np.Close()
+}
+
+// dialFunc dials remote address (e.g., sshTunnel.Dial).
+type dialFunc func(network, addr string) (net.Conn, error)
+
+// closeWriter is implemented by connections which can be half-closed
+// (e.g., *net.TCPConn and ssh channel).
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// serve accepts connections on localListener and handles each of them
+// in its own goroutine (e.g., forwards it to remote server). Failure of
+// a connection is only logged. It returns when localListener is closed
+// (after doneCh is closed) or accepting fails permanently (error is
+// sent to errCh).
+func (s *PortForwardServer) serve(localListener net.Listener, handle func(net.Conn), doneCh chan struct{}, errCh chan error) {
+	var tempDelay time.Duration
+	for {
+
+		// Accept request and start local connection
+		localConn, err := localListener.Accept()
+		if err != nil {
+			select {
+			case <-doneCh:
+				return
+			default:
+			}
+
+			// Retry temporary error (e.g., too many open files) as net/http does
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if tempDelay > time.Second {
+					tempDelay = time.Second
+				}
+				s.Logger.Printf("[ERROR] Failed to accept request: %s (retry in %s)", err, tempDelay)
+				time.Sleep(tempDelay)
+				continue
+			}
+
+			select {
+			case errCh <- fmt.Errorf("failed to accept request: %s", err):
+			case <-doneCh:
+			}
+			return
+		}
+		tempDelay = 0
+		s.Logger.Printf("[DEBUG] Accept request from %s", localConn.RemoteAddr())
+
+		go handle(localConn)
 	}
+}
+
+// forward forwards traffic between localConn and remoteServer.
+// Connection is counted in stats.
+func (s *PortForwardServer) forward(localConn net.Conn, dial dialFunc, remoteServer string, stats *connStats) {
+	defer localConn.Close()
+	defer stats.connect()()
 
-	signer, _ := ssh.ParsePrivateKey(buff)
+	// Establish connection with remote server via SSH connection
+	start := time.Now()
+	remoteConn, err := dial("tcp", remoteServer)
+	stats.dialed(time.Since(start), err)
 	if err != nil {
-		return nil, err
+		s.Logger.Printf(
+			"[ERROR] Failed to establish connection with remote server %s on boot2docker: %s "+
+				"(This error happens when kubelet is not working. Check it via `docker ps` command)",
+			remoteServer, err)
+		return
 	}
+	s.Logger.Printf("[DEBUG] Establish connection with remote server %s", remoteServer)
 
-	return ssh.PublicKeys(signer), nil
+	s.pipe(localConn, remoteConn, stats)
+}
+
+// pipe transfers data between localConn and remoteConn until both
+// directions are finished. When one side finishes writing, it's
+// propagated to the other side by half-close, so the other direction
+// can still finish its transfer. remoteConn is closed when it returns.
+// Transferred bytes are counted in stats, and failure of the connection
+// is counted once even if both directions fail.
+func (s *PortForwardServer) pipe(localConn, remoteConn net.Conn, stats *connStats) {
+	defer remoteConn.Close()
+
+	doneRWCh := make(chan error, 2)
+	go func() {
+		s.Logger.Printf("[DEBUG] Start data transfer from remote server to local")
+		_, err := io.Copy(countWriter{localConn, &stats.bytesReceived}, remoteConn)
+		if err != nil {
+			s.Logger.Printf(
+				"[ERROR] Failed to transfer from remote server to local: %s", err)
+		}
+		closeWrite(localConn)
+		doneRWCh <- err
+	}()
+
+	go func() {
+		s.Logger.Printf("[DEBUG] Start data transfer from local server to remote")
+		_, err := io.Copy(countWriter{remoteConn, &stats.bytesSent}, localConn)
+		if err != nil {
+			s.Logger.Printf("[ERROR] Failed to transfer from local server to remote: %s", err)
+		}
+		closeWrite(remoteConn)
+		doneRWCh <- err
+	}()
+
+	err1, err2 := <-doneRWCh, <-doneRWCh
+	if err1 != nil || err2 != nil {
+		stats.failed()
+	}
+	s.Logger.Printf("[DEBUG] Finish forwarding")
+}
+
+// closeWrite half-closes conn if it's supported. Otherwise conn is
+// closed, since the peer can't know the end of data.
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(closeWriter); ok {
+		cw.CloseWrite()
+		return
+	}
+	conn.Close()
 }
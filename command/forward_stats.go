@@ -0,0 +1,95 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+type ForwardStatsCommand struct {
+	Meta
+}
+
+func (c *ForwardStatsCommand) Run(args []string) int {
+
+	var statsAddr string
+	flags := c.Meta.flagSet("forward stats")
+	flags.StringVar(&statsAddr, "stats-addr", "", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Port forwarding server of the cluster records its address
+	if statsAddr == "" {
+		var err error
+		statsAddr, err = forwardStatsAddr(c.clusterName())
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to read statistics address: %s", err))
+			return 1
+		}
+	}
+
+	body, err := httpGet(statsAddr, "/stats")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to get statistics from %s: %s", statsAddr, err))
+		c.Ui.Error("Port forwarding server doesn't serve statistics by default.")
+		c.Ui.Error(fmt.Sprintf(
+			"Run it with -stats-addr=%s (e.g., `forward -detach -stats-addr=%s`)", statsAddr, statsAddr))
+		return 1
+	}
+
+	var snapshot []MappingStats
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to parse statistics: %s", err))
+		return 1
+	}
+
+	c.outputStats(snapshot)
+	return 0
+}
+
+func (c *ForwardStatsCommand) outputStats(snapshot []MappingStats) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MAPPING\tACTIVE\tTOTAL\tSENT\tRECEIVED\tDIAL LATENCY\tERRORS")
+	for _, m := range snapshot {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\t%d\n",
+			m.Name, m.Active, m.Total, m.BytesSent, m.BytesReceived, m.DialLatency(), m.Errors)
+	}
+	w.Flush()
+
+	c.Ui.Output(strings.TrimSpace(buf.String()))
+}
+
+func (c *ForwardStatsCommand) Synopsis() string {
+	return "Show statistics of port forwarding server"
+}
+
+func (c *ForwardStatsCommand) Help() string {
+	helpText := `Show statistics of connections of each mapping of port
+forwarding server of the cluster which runs with -stats-addr (statistics
+are not served without it). Sent and received are bytes from local to
+remote and from remote to local. Dial latency is average time to dial
+remote server via SSH connection.
+
+  $ boot2k8s forward -detach -stats-addr=localhost:8079
+  $ boot2k8s forward stats
+
+Options:
+
+  -name=NAME      Name of the cluster. Default is "default".
+
+  -stats-addr=ADDR
+                  Address of statistics endpoint of port forwarding
+                  server. Default is the address which the server of
+                  the cluster runs with, or localhost:8079.
+`
+	return strings.TrimSpace(helpText)
+}
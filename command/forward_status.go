@@ -0,0 +1,59 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ForwardStatusCommand struct {
+	Meta
+}
+
+func (c *ForwardStatusCommand) Run(args []string) int {
+
+	flags := c.Meta.flagSet("forward status")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return ExitCodeError
+	}
+
+	name := c.clusterName()
+	pid, err := forwardDaemonPid(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to check port forwarding server: %s", err))
+		return ExitCodeError
+	}
+
+	if pid == 0 {
+		c.Ui.Output("Port forwarding server is not running")
+		return ExitCodeNotRunning
+	}
+
+	_, logPath, _ := forwardDaemonFiles(name)
+	c.Ui.Output(fmt.Sprintf("Port forwarding server is running (pid %d)", pid))
+	c.Ui.Output(fmt.Sprintf("Logs: %s", logPath))
+	return ExitCodeHealthy
+}
+
+func (c *ForwardStatusCommand) Synopsis() string {
+	return "Show whether port forwarding server runs in background"
+}
+
+func (c *ForwardStatusCommand) Help() string {
+	helpText := `Show whether port forwarding server of the cluster which is
+started by "forward -detach" (or "up -detach") is running.
+
+Options:
+
+  -name=NAME      Name of the cluster. Default is "default".
+
+Exit codes:
+
+  0    Port forwarding server is running
+  1    Failed to check it
+  2    Port forwarding server is not running
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ForwardStopCommand struct {
+	Meta
+}
+
+func (c *ForwardStopCommand) Run(args []string) int {
+
+	flags := c.Meta.flagSet("forward stop")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	pid, err := stopForwardDaemon(c.clusterName())
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to stop port forwarding server: %s", err))
+		return 1
+	}
+
+	if pid == 0 {
+		c.Ui.Info("Port forwarding server is not running")
+		return 0
+	}
+
+	c.Ui.Info(fmt.Sprintf("Successfully stop port forwarding server (pid %d)", pid))
+	return 0
+}
+
+func (c *ForwardStopCommand) Synopsis() string {
+	return "Stop port forwarding server which runs in background"
+}
+
+func (c *ForwardStopCommand) Help() string {
+	helpText := `Stop port forwarding server of the cluster which is started by
+"forward -detach" (or "up -detach").
+
+Options:
+
+  -name=NAME      Name of the cluster. Default is "default".
+`
+	return strings.TrimSpace(helpText)
+}
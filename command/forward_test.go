@@ -0,0 +1,310 @@
+package command
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestForwardCommand_implement(t *testing.T) {
+	var _ cli.Command = &ForwardCommand{}
+}
+
+func TestForwardStopCommand_implement(t *testing.T) {
+	var _ cli.Command = &ForwardStopCommand{}
+}
+
+func TestForwardStatusCommand_implement(t *testing.T) {
+	var _ cli.Command = &ForwardStatusCommand{}
+}
+
+func TestForwardStatsCommand_implement(t *testing.T) {
+	var _ cli.Command = &ForwardStatsCommand{}
+}
+
+func TestForwardArgs(t *testing.T) {
+	mappings := []PortMapping{{Local: "localhost:4001", Remote: "localhost:4001"}}
+	sshFlags := SSHConfig{Port: "22", InsecureSkipHostKey: true}
+	got := forwardArgs("dev", "debug", mappings, sshFlags, false, "localhost:1080", "localhost:8079")
+	want := []string{
+		"-name=dev",
+		"-forward=always",
+		"-log-level=debug",
+		"-watch-services=false",
+		"-L=localhost:4001:localhost:4001",
+		"-ssh-port=22",
+		"-insecure-skip-host-key",
+		"-socks=localhost:1080",
+		"-stats-addr=localhost:8079",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expect %v to eq %v", got, want)
+	}
+
+	// Arguments must be parsed by forward command
+	for _, arg := range got {
+		if strings.HasPrefix(arg, "-L=") {
+			if _, err := parsePortMapping(strings.TrimPrefix(arg, "-L=")); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+	}
+}
+
+func TestDockerIsLocal(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		want     bool
+	}{
+		{"", true},
+		{"unix:///var/run/docker.sock", true},
+		{"tcp://127.0.0.1:2375", true},
+		{"tcp://localhost:2375", true},
+		{"tcp://[::1]:2375", true},
+		{"tcp://192.168.59.103:2376", false},
+		{"tcp://docker.example.com:2376", false},
+	}
+
+	for _, tc := range cases {
+		if got := dockerIsLocal(tc.endpoint); got != tc.want {
+			t.Fatalf("%s: expect %v to eq %v", tc.endpoint, got, tc.want)
+		}
+	}
+}
+
+func TestNeedForward(t *testing.T) {
+	defer os.Setenv("DOCKER_HOST", os.Getenv("DOCKER_HOST"))
+	os.Setenv("DOCKER_HOST", "unix:///var/run/docker.sock")
+
+	cases := []struct {
+		mode string
+		want bool
+	}{
+		{ForwardAlways, true},
+		{ForwardNever, false},
+		{ForwardAuto, false},
+	}
+
+	for _, tc := range cases {
+		got, err := needForward(tc.mode, DefaultPorts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("%s: expect %v to eq %v", tc.mode, got, tc.want)
+		}
+	}
+
+	if _, err := needForward("sometimes", DefaultPorts); err == nil {
+		t.Fatal("expect invalid mode to fail")
+	}
+}
+
+func TestValidateForwardMode(t *testing.T) {
+	for _, mode := range []string{ForwardAuto, ForwardAlways, ForwardNever} {
+		if err := validateForwardMode(mode); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	for _, mode := range []string{"", "sometimes", "Always"} {
+		if err := validateForwardMode(mode); err == nil {
+			t.Fatalf("expect %q to fail", mode)
+		}
+	}
+}
+
+func TestParsePortMapping(t *testing.T) {
+	cases := []struct {
+		in   string
+		want PortMapping
+	}{
+		{"4001:4001", PortMapping{Local: "localhost:4001", Remote: "localhost:4001"}},
+		{"10250:127.0.0.1:10250", PortMapping{Local: "localhost:10250", Remote: "127.0.0.1:10250"}},
+		{"0.0.0.0:30080:localhost:30000", PortMapping{Local: "0.0.0.0:30080", Remote: "localhost:30000"}},
+	}
+
+	for _, tc := range cases {
+		got, err := parsePortMapping(tc.in)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("expect %#v to eq %#v", got, tc.want)
+		}
+	}
+
+	for _, in := range []string{"8080", "localhost:8080", "8080:", "a:b:c:d:e", "8080:70000"} {
+		if _, err := parsePortMapping(in); err == nil {
+			t.Fatalf("expect %q to fail", in)
+		}
+	}
+}
+
+func TestPortMappings_withDefault(t *testing.T) {
+	var mappings portMappings
+	got := mappings.withDefault(DefaultPorts)
+	if len(got) != 1 || got[0].Local != "localhost:8080" || got[0].Remote != "localhost:8080" {
+		t.Fatalf("expect default mapping: %#v", got)
+	}
+
+	// API server mapping is kept with other mappings
+	mappings.Set("4001:4001")
+	mappings.Set("10250:10250")
+	got = mappings.withDefault(DefaultPorts)
+	if len(got) != 3 || got[0].Local != "localhost:8080" || got[1].Local != "localhost:4001" {
+		t.Fatalf("expect default mapping and given mappings: %#v", got)
+	}
+
+	// API server mapping is not duplicated
+	mappings.Set("8080:8080")
+	if got := mappings.withDefault(DefaultPorts); len(got) != 3 {
+		t.Fatalf("expect %d to eq 3", len(got))
+	}
+
+	// Each cluster has its own API server port
+	got = mappings.withDefault(clusterPorts(1))
+	if len(got) != 4 || got[0].Local != "localhost:8180" || got[0].Remote != "localhost:8180" {
+		t.Fatalf("expect mapping of the cluster: %#v", got)
+	}
+}
+
+// startEchoServer starts server which writes back data until EOF
+// and then closes the connection.
+func startEchoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return l
+}
+
+func TestPortForwardServer_serve(t *testing.T) {
+	echo := startEchoServer(t)
+	defer echo.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &PortForwardServer{Logger: log.New(ioutil.Discard, "", 0)}
+	doneCh, errCh := make(chan struct{}), make(chan error)
+	go s.serve(l, func(conn net.Conn) {
+		s.forward(conn, net.Dial, echo.Addr().String(), &connStats{})
+	}, doneCh, errCh)
+	defer func() {
+		close(doneCh)
+		l.Close()
+	}()
+
+	// Long-lived connection must not block others
+	idle, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+
+	resultCh := make(chan string)
+	go func() {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			resultCh <- err.Error()
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("hello"))
+
+		// Half-close must be propagated to remote, then remote closes
+		conn.(*net.TCPConn).CloseWrite()
+		buf, _ := ioutil.ReadAll(conn)
+		resultCh <- string(buf)
+	}()
+
+	select {
+	case got := <-resultCh:
+		if got != "hello" {
+			t.Fatalf("expect %q to eq %q", got, "hello")
+		}
+	case err := <-errCh:
+		t.Fatalf("err: %s", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout: connection is blocked")
+	}
+}
+
+func TestPortForwardServer_serveDialError(t *testing.T) {
+	// Remote server which is not listening
+	remote, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &PortForwardServer{Logger: log.New(ioutil.Discard, "", 0)}
+	stats := newForwardStats()
+	doneCh, errCh := make(chan struct{}), make(chan error)
+	go s.serve(l, func(conn net.Conn) {
+		s.forward(conn, net.Dial, remote.Addr().String(), stats.Mapping("remote"))
+	}, doneCh, errCh)
+	defer func() {
+		close(doneCh)
+		l.Close()
+	}()
+
+	// Failed connection is closed and server keeps accepting
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+			t.Fatalf("expect %v to eq EOF", err)
+		}
+		conn.Close()
+	}
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("expect no error: %s", err)
+	default:
+	}
+
+	got := stats.Snapshot()[0]
+	if got.Total != 2 || got.Active != 0 || got.Errors != 2 {
+		t.Fatalf("expect total 2, active 0 and errors 2: %#v", got)
+	}
+}
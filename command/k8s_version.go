@@ -0,0 +1,74 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tcnksm/boot2kubernetes/config"
+	"gopkg.in/yaml.v2"
+)
+
+// VersionCatalogFile is name of embedded kubernetes version catalog.
+const VersionCatalogFile = "versions.yml"
+
+// K8sVersion describes docker images and flags which are needed
+// to start the specific version of kubernetes. Flags are rendered as
+// text/template with ports of the cluster (e.g., {{.APIServerPort}}).
+type K8sVersion struct {
+	Version                string `yaml:"version"`
+	Etcd                   string `yaml:"etcd"`
+	Hyperkube              string `yaml:"hyperkube"`
+	KubeletFlags           string `yaml:"kubelet_flags"`
+	ProxyFlags             string `yaml:"proxy_flags"`
+	APIServerFlags         string `yaml:"apiserver_flags"`
+	ControllerManagerFlags string `yaml:"controller_manager_flags"`
+	SchedulerFlags         string `yaml:"scheduler_flags"`
+
+	// RootDirFlag is kubelet flag to set its root directory
+	// (e.g., --root-dir). Its spelling depends on the version.
+	RootDirFlag string `yaml:"root_dir_flag"`
+}
+
+// VersionCatalog is the list of kubernetes versions boot2k8s supports.
+type VersionCatalog struct {
+	Default  string       `yaml:"default"`
+	Versions []K8sVersion `yaml:"versions"`
+}
+
+// LoadVersionCatalog reads embedded version catalog.
+func LoadVersionCatalog() (*VersionCatalog, error) {
+	buf, err := config.Asset(VersionCatalogFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", VersionCatalogFile, err)
+	}
+
+	var catalog VersionCatalog
+	if err := yaml.Unmarshal(buf, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", VersionCatalogFile, err)
+	}
+
+	return &catalog, nil
+}
+
+// Lookup returns K8sVersion of the given version. If version is empty,
+// it returns default one. Prefix 'v' (e.g., v1.0.1) is allowed.
+func (c *VersionCatalog) Lookup(version string) (*K8sVersion, error) {
+	if version == "" {
+		version = c.Default
+	}
+	version = strings.TrimPrefix(version, "v")
+
+	for i := range c.Versions {
+		if c.Versions[i].Version == version {
+			return &c.Versions[i], nil
+		}
+	}
+
+	available := make([]string, 0, len(c.Versions))
+	for _, v := range c.Versions {
+		available = append(available, v.Version)
+	}
+
+	return nil, fmt.Errorf("kubernetes version %q is not supported (available: %s)",
+		version, strings.Join(available, ", "))
+}
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"testing"
+)
+
+func TestVersionCatalog_Lookup(t *testing.T) {
+	catalog := &VersionCatalog{
+		Default: "1.0.1",
+		Versions: []K8sVersion{
+			{Version: "0.21.2", Hyperkube: "hyperkube:v0.21.2"},
+			{Version: "1.0.1", Hyperkube: "hyperkube:v1.0.1"},
+		},
+	}
+
+	cases := []struct {
+		version string
+		expect  string
+	}{
+		{"", "hyperkube:v1.0.1"},
+		{"0.21.2", "hyperkube:v0.21.2"},
+		{"v0.21.2", "hyperkube:v0.21.2"},
+	}
+
+	for _, tc := range cases {
+		v, err := catalog.Lookup(tc.version)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if v.Hyperkube != tc.expect {
+			t.Fatalf("expect %q to eq %q", v.Hyperkube, tc.expect)
+		}
+	}
+
+	if _, err := catalog.Lookup("0.1.0"); err == nil {
+		t.Fatalf("expect error for unsupported version")
+	}
+}
+
+func TestLoadVersionCatalog(t *testing.T) {
+	catalog, err := LoadVersionCatalog()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := catalog.Lookup(""); err != nil {
+		t.Fatalf("expect default version to be in catalog: %s", err)
+	}
+
+	// Pod containers of the cluster are found by kubelet root directory
+	for _, v := range catalog.Versions {
+		if v.RootDirFlag == "" {
+			t.Fatalf("expect %s to have root_dir_flag", v.Version)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvKubeconfig is environmental variable which kubectl reads to find
+// kubeconfig files. If multiple files are set, the first one is used.
+const EnvKubeconfig = "KUBECONFIG"
+
+// Kubeconfig is kubectl config file. Only fields which boot2k8s touches
+// are defined, others are kept as they are via Extra.
+type Kubeconfig struct {
+	APIVersion     string                 `yaml:"apiVersion,omitempty"`
+	Kind           string                 `yaml:"kind,omitempty"`
+	Clusters       []KubeconfigEntry      `yaml:"clusters"`
+	Users          []KubeconfigEntry      `yaml:"users"`
+	Contexts       []KubeconfigEntry      `yaml:"contexts"`
+	CurrentContext string                 `yaml:"current-context"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+// KubeconfigEntry is a named entry of clusters, users or contexts.
+// Its body is kept as it is, since boot2k8s doesn't need to know it.
+type KubeconfigEntry struct {
+	Name    string                 `yaml:"name"`
+	Cluster map[string]interface{} `yaml:"cluster,omitempty"`
+	User    map[string]interface{} `yaml:"user,omitempty"`
+	Context map[string]interface{} `yaml:"context,omitempty"`
+}
+
+// kubeconfigName returns name of the cluster, user and context entry
+// of the cluster in kubeconfig.
+func kubeconfigName(name string) string {
+	return "boot2k8s-" + name
+}
+
+// kubeconfigPath returns path of kubeconfig file which boot2k8s writes.
+func kubeconfigPath() (string, error) {
+	if paths := filepath.SplitList(os.Getenv(EnvKubeconfig)); len(paths) > 0 && paths[0] != "" {
+		return paths[0], nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".kube", "config"), nil
+}
+
+// kubectlServer returns address of API server which listens on ports
+// and kubectl connects to. API server only listens on localhost of docker
+// host, so if docker runs on remote host (e.g., boot2docker VM), it's the
+// local end of port forwarding. It doesn't matter who runs forwarding
+// (e.g., "up", "forward -detach" or user's own ssh -L).
+func kubectlServer(ports ClusterPorts) string {
+	if !dockerIsLocal(os.Getenv("DOCKER_HOST")) {
+		return ports.LocalServer()
+	}
+	return apiServerAddr(ports)
+}
+
+// newKubeconfig returns kubeconfig which only has entries of the cluster.
+// API server is served without authentication, so user entry is empty.
+func newKubeconfig(name, server string) *Kubeconfig {
+	entryName := kubeconfigName(name)
+	return &Kubeconfig{
+		APIVersion: "v1",
+		Kind:       "Config",
+		Clusters: []KubeconfigEntry{{
+			Name:    entryName,
+			Cluster: map[string]interface{}{"server": "http://" + server},
+		}},
+		Users: []KubeconfigEntry{{
+			Name: entryName,
+			User: map[string]interface{}{},
+		}},
+		Contexts: []KubeconfigEntry{{
+			Name: entryName,
+			Context: map[string]interface{}{
+				"cluster": entryName,
+				"user":    entryName,
+			},
+		}},
+		CurrentContext: entryName,
+	}
+}
+
+// loadKubeconfig reads kubeconfig file. If file doesn't exist,
+// it returns empty kubeconfig.
+func loadKubeconfig(path string) (*Kubeconfig, error) {
+	var k Kubeconfig
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Kubeconfig{APIVersion: "v1", Kind: "Config"}, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(buf, &k); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+
+	return &k, nil
+}
+
+// Save writes kubeconfig to path. It may contain credentials of
+// other clusters, so it's only readable by the user.
+func (k *Kubeconfig) Save(path string) error {
+	buf, err := yaml.Marshal(k)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf, 0600)
+}
+
+// Merge adds entries of other. Entries which have the same name are
+// replaced. Current context is switched to the one of other.
+func (k *Kubeconfig) Merge(other *Kubeconfig) {
+	for _, e := range other.Clusters {
+		k.Clusters = append(removeEntry(k.Clusters, e.Name), e)
+	}
+	for _, e := range other.Users {
+		k.Users = append(removeEntry(k.Users, e.Name), e)
+	}
+	for _, e := range other.Contexts {
+		k.Contexts = append(removeEntry(k.Contexts, e.Name), e)
+	}
+
+	if other.CurrentContext != "" {
+		k.CurrentContext = other.CurrentContext
+	}
+}
+
+// Remove removes cluster, user and context entries which have name.
+// It returns false if there is no such entry.
+func (k *Kubeconfig) Remove(name string) bool {
+	n := len(k.Clusters) + len(k.Users) + len(k.Contexts)
+	k.Clusters = removeEntry(k.Clusters, name)
+	k.Users = removeEntry(k.Users, name)
+	k.Contexts = removeEntry(k.Contexts, name)
+
+	if k.CurrentContext == name {
+		k.CurrentContext = ""
+	}
+
+	return n != len(k.Clusters)+len(k.Users)+len(k.Contexts)
+}
+
+func removeEntry(entries []KubeconfigEntry, name string) []KubeconfigEntry {
+	kept := make([]KubeconfigEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Name != name {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// writeKubeconfig merges entries of the cluster into kubeconfig file
+// and returns its path.
+func writeKubeconfig(name, server string) (string, error) {
+	path, err := kubeconfigPath()
+	if err != nil {
+		return "", err
+	}
+
+	k, err := loadKubeconfig(path)
+	if err != nil {
+		return "", err
+	}
+
+	k.Merge(newKubeconfig(name, server))
+	return path, k.Save(path)
+}
+
+// removeKubeconfig removes entries of the cluster from kubeconfig file.
+// File is not touched if it has no entries of the cluster.
+func removeKubeconfig(name string) error {
+	path, err := kubeconfigPath()
+	if err != nil {
+		return err
+	}
+
+	k, err := loadKubeconfig(path)
+	if err != nil {
+		return err
+	}
+
+	if !k.Remove(kubeconfigName(name)) {
+		return nil
+	}
+	return k.Save(path)
+}
+
+type KubeconfigCommand struct {
+	Meta
+}
+
+func (c *KubeconfigCommand) Run(args []string) int {
+
+	flags := c.Meta.flagSet("kubeconfig")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	name := c.clusterName()
+	state, err := LoadClusterState(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
+		return 1
+	}
+
+	// Same address as up writes
+	server := kubectlServer(DefaultPorts)
+	if state != nil {
+		server = state.APIServer
+
+		// State of older boot2k8s has address on docker host
+		if state.Forwarded {
+			server = clusterPorts(state.PortOffset).LocalServer()
+		}
+	}
+
+	buf, err := yaml.Marshal(newKubeconfig(name, server))
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to marshal kubeconfig: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(strings.TrimSpace(string(buf)))
+	return 0
+}
+
+func (c *KubeconfigCommand) Synopsis() string {
+	return "Print kubeconfig to connect kubernetes cluster by kubectl"
+}
+
+func (c *KubeconfigCommand) Help() string {
+	helpText := `Print kubeconfig to connect kubernetes cluster by kubectl.
+It's the same entries which "up" merges into ~/.kube/config (or the first
+file of KUBECONFIG). For example, in CI,
+
+  $ boot2k8s kubeconfig > kubeconfig
+  $ kubectl --kubeconfig=kubeconfig get nodes
+
+Options:
+
+  -name=NAME    Name of the cluster. Default is "default".
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,117 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestKubeconfigCommand_implement(t *testing.T) {
+	var _ cli.Command = &KubeconfigCommand{}
+}
+
+func TestKubeconfig_mergeAndRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config")
+	ioutil.WriteFile(path, []byte(`
+apiVersion: v1
+kind: Config
+preferences:
+  colors: true
+clusters:
+- name: other
+  cluster:
+    server: https://example.com
+users:
+- name: other
+  user:
+    token: secret
+contexts:
+- name: other
+  context:
+    cluster: other
+    user: other
+current-context: other
+`), 0600)
+
+	defer os.Setenv(EnvKubeconfig, os.Getenv(EnvKubeconfig))
+	os.Setenv(EnvKubeconfig, path+string(os.PathListSeparator)+filepath.Join(dir, "unused"))
+
+	if _, err := writeKubeconfig("dev", "127.0.0.1:8080"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing twice must not duplicate entries
+	if _, err := writeKubeconfig("dev", "127.0.0.1:8080"); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := loadKubeconfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(k.Clusters) != 2 || len(k.Users) != 2 || len(k.Contexts) != 2 {
+		t.Fatalf("expect 2 entries each: %#v", k)
+	}
+
+	if k.CurrentContext != "boot2k8s-dev" {
+		t.Fatalf("expect %q to eq %q", k.CurrentContext, "boot2k8s-dev")
+	}
+
+	if got := k.Clusters[1].Cluster["server"]; got != "http://127.0.0.1:8080" {
+		t.Fatalf("expect %q to eq %q", got, "http://127.0.0.1:8080")
+	}
+
+	if _, ok := k.Extra["preferences"]; !ok {
+		t.Fatalf("expect preferences to be kept: %#v", k.Extra)
+	}
+
+	if err := removeKubeconfig("dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err = loadKubeconfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(k.Clusters) != 1 || k.Clusters[0].Name != "other" || k.Users[0].User["token"] != "secret" {
+		t.Fatalf("expect only other entries to be kept: %#v", k)
+	}
+
+	if k.CurrentContext != "" {
+		t.Fatalf("expect %q to be empty", k.CurrentContext)
+	}
+}
+
+func TestKubectlServer(t *testing.T) {
+	defer os.Setenv("DOCKER_HOST", os.Getenv("DOCKER_HOST"))
+
+	cases := []struct {
+		dockerHost string
+		expect     string
+	}{
+		{"", "localhost:8180"},
+		{"tcp://127.0.0.1:2375", "127.0.0.1:8180"},
+
+		// API server on remote docker host is reached via port
+		// forwarding, even if it's not started by boot2k8s
+		{"tcp://192.168.59.103:2376", "localhost:8180"},
+	}
+
+	for _, tc := range cases {
+		os.Setenv("DOCKER_HOST", tc.dockerHost)
+		if got := kubectlServer(clusterPorts(1)); got != tc.expect {
+			t.Fatalf("%q: expect %q to eq %q", tc.dockerHost, got, tc.expect)
+		}
+	}
+}
@@ -1,13 +1,13 @@
 package command
 
 import (
-	"bufio"
-	"encoding/json"
-	"flag"
+	"bytes"
 	"fmt"
-	"io"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/docker/libcompose/docker"
+	"github.com/samalba/dockerclient"
 )
 
 type ListCommand struct {
@@ -16,21 +16,12 @@ type ListCommand struct {
 
 func (c *ListCommand) Run(args []string) int {
 
-	var insecure bool
-	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	var insecure, all bool
+	flags := c.Meta.flagSet("list")
 	flags.BoolVar(&insecure, "insecure", false, "")
+	flags.BoolVar(&all, "all", false, "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 
-	errR, errW := io.Pipe()
-	errScanner := bufio.NewScanner(errR)
-	go func() {
-		for errScanner.Scan() {
-			c.Ui.Error(errScanner.Text())
-		}
-	}()
-
-	flags.SetOutput(errW)
-
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
@@ -50,32 +41,94 @@ func (c *ListCommand) Run(args []string) int {
 
 	client := clientFactory.Create(nil)
 
-	// Marshaling to post filter as API request
-	filterK8SRelatedStr, _ := json.Marshal(FilterK8SRelated)
-	relatedContainers, err := client.ListContainers(true, false, (string)(filterK8SRelatedStr))
+	if all {
+		clusters, err := listClusterContainers(client)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to list containers: %s", err))
+			return 1
+		}
+
+		if len(clusters) < 1 {
+			c.Ui.Info("There are no clusters. Start it by `up` command")
+			return 0
+		}
+
+		for _, name := range clusterNames(clusters) {
+			c.Ui.Output(fmt.Sprintf("CLUSTER %s", name))
+			c.outputContainers(clusters[name])
+			c.Ui.Output("")
+		}
+		return 0
+	}
+
+	name := c.clusterName()
+	containers, err := listServiceContainers(client, name, "")
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Failed to list containers: %s", err))
 		return 1
 	}
 
-	if len(relatedContainers) < 1 {
-		c.Ui.Info("There are no containers which are labeled io.kubernetes.pod.name")
-		return 0
+	state, err := LoadClusterState(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
+		return 1
 	}
 
-	c.Ui.Output("NAME")
-	for _, container := range relatedContainers {
-		c.Ui.Output(fmt.Sprintf("%s", container.Names[0]))
+	// Cluster which doesn't exist has no pod containers to warn about
+	var relatedContainers []dockerclient.Container
+	if len(containers) > 0 || state != nil {
+		if rootDir := podRootDir(c.Ui, name, state); rootDir != "" {
+			relatedContainers, err = listPodContainers(client, rootDir)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Failed to list containers: %s", err))
+				return 1
+			}
+		}
 	}
 
+	if len(containers) < 1 && len(relatedContainers) < 1 {
+		c.Ui.Info(fmt.Sprintf(
+			"There are no containers of cluster %q", name))
+		return 0
+	}
+
+	c.outputContainers(append(containers, relatedContainers...))
 	return 0
 }
 
+func (c *ListCommand) outputContainers(containers []dockerclient.Container) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS")
+	for _, container := range containers {
+		fmt.Fprintf(w, "%s\t%s\n", container.Names[0], container.Status)
+	}
+	w.Flush()
+
+	c.Ui.Output(strings.TrimSpace(buf.String()))
+}
+
 func (c *ListCommand) Synopsis() string {
-	return "List all containers which are labeled `io.kubernetes.pod.name`"
+	return "List containers of kubernetes cluster"
 }
 
 func (c *ListCommand) Help() string {
-	return "List all containers which are labeled `io.kubernetes.pod.name`"
+	helpText := `List containers of kubernetes cluster. Containers which
+are created by kubelet of the cluster (pod containers) are also listed.
+For the cluster created by older boot2k8s, which can't identify its pod
+containers, they are not listed.
+
+Options:
+
+  -name=NAME    Name of the cluster. Default is "default".
+
+  -all          List containers of all clusters grouped by cluster name.
+
+  -insecure     Allow insecure non-TLS connection to docker client.
+`
+	return strings.TrimSpace(helpText)
 }
@@ -0,0 +1,269 @@
+package command
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/libcompose/docker"
+	"github.com/samalba/dockerclient"
+)
+
+// logColors is ANSI color codes which are used for prefix of each service.
+var logColors = map[string]int{
+	ServiceEtcd:              36, // cyan
+	ServiceAPIServer:         32, // green
+	ServiceControllerManager: 34, // blue
+	ServiceScheduler:         31, // red
+	ServiceMaster:            33, // yellow
+	ServiceProxy:             35, // magenta
+}
+
+type LogsCommand struct {
+	Meta
+}
+
+func (c *LogsCommand) Run(args []string) int {
+
+	var insecure, follow bool
+	var tail int64
+	var since string
+	flags := c.Meta.flagSet("logs")
+	flags.BoolVar(&insecure, "insecure", false, "")
+	flags.BoolVar(&follow, "follow", false, "")
+	flags.Int64Var(&tail, "tail", 0, "")
+	flags.StringVar(&since, "since", "", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	services := []string{ServiceEtcd, ServiceAPIServer, ServiceControllerManager, ServiceScheduler, ServiceMaster, ServiceProxy}
+	parsedArgs := flags.Args()
+	switch len(parsedArgs) {
+	case 0:
+	case 1:
+		if _, ok := logColors[parsedArgs[0]]; !ok {
+			c.Ui.Error(fmt.Sprintf(
+				"Invalid component %q: must be one of %s", parsedArgs[0], strings.Join(services, ", ")))
+			return 1
+		}
+		services = parsedArgs
+	default:
+		c.Ui.Error("Too many arguments: only one component can be specified")
+		c.Ui.Error(c.Help())
+		return 1
+	}
+
+	var sinceTime time.Time
+	if since != "" {
+		var err error
+		sinceTime, err = parseSince(since, time.Now())
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -since: %s", err))
+			return 1
+		}
+	}
+
+	// Set up docker client
+	clientFactory, err := docker.NewDefaultClientFactory(
+		docker.ClientOpts{
+			TLS: !insecure,
+		},
+	)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to construct Docker client: %s", err))
+		return 1
+	}
+
+	client := clientFactory.Create(nil)
+
+	// Collect containers of the services
+	var containers []dockerclient.Container
+	serviceOf := make(map[string]string)
+	for _, service := range services {
+		serviceContainers, err := listServiceContainers(client, c.clusterName(), service)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to list containers: %s", err))
+			return 1
+		}
+
+		for _, container := range serviceContainers {
+			serviceOf[container.Id] = service
+		}
+		containers = append(containers, serviceContainers...)
+	}
+
+	if len(containers) < 1 {
+		c.Ui.Info("There are no containers of the cluster. Start it by `up` command")
+		return 0
+	}
+
+	logOpts := &dockerclient.LogOptions{
+		Follow:     follow,
+		Stdout:     true,
+		Stderr:     true,
+		Timestamps: true,
+		Tail:       tail,
+	}
+
+	// Prefix is needed only when logs of multiple services are interleaved
+	width := 0
+	for _, service := range services {
+		if len(service) > width {
+			width = len(service)
+		}
+	}
+
+	lineCh, errCh := make(chan string), make(chan error)
+	var wg sync.WaitGroup
+	for _, container := range containers {
+		prefix := ""
+		if len(services) > 1 {
+			service := serviceOf[container.Id]
+			prefix = fmt.Sprintf("\x1b[%dm%-*s |\x1b[0m ", logColors[service], width, service)
+		}
+
+		wg.Add(1)
+		go func(container dockerclient.Container, prefix string) {
+			defer wg.Done()
+			if err := streamLogs(client, container.Id, logOpts, sinceTime, func(line string) {
+				lineCh <- prefix + line
+			}); err != nil {
+				errCh <- fmt.Errorf("failed to read logs of %s: %s", container.Names[0], err)
+			}
+		}(container, prefix)
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+
+	exitCode := 0
+	for {
+		select {
+		case line := <-lineCh:
+			c.Ui.Output(line)
+		case err := <-errCh:
+			c.Ui.Error(fmt.Sprintf("Error: %s", err))
+			exitCode = 1
+		case <-sigCh:
+			return exitCode
+		case <-doneCh:
+			return exitCode
+		}
+	}
+}
+
+func (c *LogsCommand) Synopsis() string {
+	return "Show logs of kubernetes cluster containers"
+}
+
+func (c *LogsCommand) Help() string {
+	helpText := `Usage: boot2k8s logs [options] [COMPONENT]
+
+  Show logs of kubernetes cluster containers. COMPONENT is one of etcd,
+  apiserver, controller-manager, scheduler, master (kubelet) and proxy.
+  If component is not specified, logs of all components are interleaved
+  with its name.
+
+Options:
+
+  -name=NAME     Name of the cluster. Default is "default".
+
+  -follow        Follow log output.
+
+  -tail=N        Number of lines to show from the end of the logs.
+                 By default, all lines are shown.
+
+  -since=TIME    Show logs since the given time. It can be duration
+                 (e.g., 10m) or RFC3339 timestamp.
+
+  -insecure      Allow insecure non-TLS connection to docker client.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// parseSince parses -since value. It's duration relative to now
+// (e.g., 10m) or RFC3339 timestamp.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither duration nor RFC3339 timestamp", since)
+	}
+	return t, nil
+}
+
+// streamLogs reads logs of the container and calls fn with each line.
+// Logs are requested with timestamp and lines before since are skipped.
+// Timestamp is trimmed before calling fn.
+func streamLogs(client dockerclient.Client, id string, opts *dockerclient.LogOptions, since time.Time, fn func(string)) error {
+	rc, err := client.ContainerLogs(id, opts)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(demuxLogStream(pw, rc))
+	}()
+
+	scanner := bufio.NewScanner(pr)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Each line starts with RFC3339Nano timestamp (e.g., 2015-08-08T12:00:00.000000000Z)
+		if i := strings.Index(line, " "); i > 0 {
+			if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+				if t.Before(since) {
+					continue
+				}
+				line = line[i+1:]
+			}
+		}
+
+		fn(line)
+	}
+
+	return scanner.Err()
+}
+
+// demuxLogStream demultiplexes docker log stream into w. Stream of
+// non-tty container consists of frames which have 8 bytes header
+// (stream type and size of payload) followed by payload.
+func demuxLogStream(w io.Writer, r io.Reader) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		size := binary.BigEndian.Uint32(header[4:])
+		if _, err := io.CopyN(w, r, int64(size)); err != nil {
+			return err
+		}
+	}
+}
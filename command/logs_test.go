@@ -0,0 +1,58 @@
+package command
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestLogsCommand_implement(t *testing.T) {
+	var _ cli.Command = &LogsCommand{}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2015, 8, 8, 12, 0, 0, 0, time.UTC)
+
+	got, err := parseSince("10m", now)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := now.Add(-10 * time.Minute); !got.Equal(want) {
+		t.Fatalf("expect %s to eq %s", got, want)
+	}
+
+	got, err = parseSince("2015-08-08T11:00:00Z", now)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := now.Add(-1 * time.Hour); !got.Equal(want) {
+		t.Fatalf("expect %s to eq %s", got, want)
+	}
+
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestDemuxLogStream(t *testing.T) {
+	var stream bytes.Buffer
+	for _, payload := range []string{"hello ", "world\n"} {
+		header := make([]byte, 8)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		stream.Write(header)
+		stream.WriteString(payload)
+	}
+
+	var out bytes.Buffer
+	if err := demuxLogStream(&out, &stream); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got, want := out.String(), "hello world\n"; got != want {
+		t.Fatalf("expect %q to eq %q", got, want)
+	}
+}
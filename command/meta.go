@@ -1,10 +1,20 @@
 package command
 
 import (
+	"bufio"
+	"flag"
+	"io"
 	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/hashicorp/logutils"
 	"github.com/mitchellh/cli"
+	"github.com/mitchellh/go-homedir"
 )
 
 func init() {
@@ -13,7 +23,87 @@ func init() {
 	logrus.SetOutput(ioutil.Discard)
 }
 
+const (
+	// StateDirName is directory under home directory where
+	// boot2k8s stores its files (e.g., crash logs).
+	StateDirName = ".boot2k8s"
+
+	// EnvName is environmental variable to set cluster name
+	// instead of -name flag.
+	EnvName = "BOOT2K8S_NAME"
+
+	// DefaultName is name of the cluster when -name is not specified.
+	DefaultName = "default"
+)
+
 // Meta contain the meta-option that nearly all subcommand inherited.
 type Meta struct {
 	Ui cli.Ui
+
+	// Name is name of the cluster which command acts on.
+	// It's set via -name flag (see flagSet). Commands which don't act
+	// on a cluster (versions and upgrade) don't have the flag.
+	Name string
+}
+
+// flagSet returns a FlagSet with the common flags (-name) that every
+// command has. Its output (e.g., parse error) is written to Ui.Error.
+func (m *Meta) flagSet(n string) *flag.FlagSet {
+	name := os.Getenv(EnvName)
+	if name == "" {
+		name = DefaultName
+	}
+
+	f := flag.NewFlagSet(n, flag.ContinueOnError)
+	f.StringVar(&m.Name, "name", name, "")
+
+	errR, errW := io.Pipe()
+	errScanner := bufio.NewScanner(errR)
+	go func() {
+		for errScanner.Scan() {
+			m.Ui.Error(errScanner.Text())
+		}
+	}()
+
+	f.SetOutput(errW)
+
+	return f
+}
+
+// clusterName returns normalized name of the cluster. Name is used for
+// docker labels and libcompose project name, so only lowercase
+// alphanumeric characters are kept (same as libcompose does).
+func (m *Meta) clusterName() string {
+	name := normalizeName(m.Name)
+	if name == "" {
+		return DefaultName
+	}
+	return name
+}
+
+var invalidNameChars = regexp.MustCompile("[^a-z0-9]+")
+
+func normalizeName(name string) string {
+	return invalidNameChars.ReplaceAllString(strings.ToLower(name), "")
+}
+
+// newLogger creates logger which filters output by the given log level.
+func newLogger(logLevel string) *log.Logger {
+	logger := log.New(&logutils.LevelFilter{
+		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERROR"},
+		MinLevel: (logutils.LogLevel)(strings.ToUpper(logLevel)),
+		Writer:   os.Stderr,
+	}, "", log.LstdFlags)
+	logger.Printf("[DEBUG] LogLevel: %s", logLevel)
+	return logger
+}
+
+// StateDir returns directory where boot2k8s stores its files.
+func StateDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, StateDirName), nil
 }
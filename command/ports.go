@@ -0,0 +1,126 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	// PortOffsetStep is difference of ports between clusters. Ports of
+	// the cluster whose port offset is n are DefaultPorts + n*PortOffsetStep
+	// (e.g., API server of the cluster whose offset is 1 listens on 8180).
+	PortOffsetStep = 100
+
+	// MaxPortOffset is the largest port offset. Ports of the cluster
+	// must not reach NodePort range (30000-32767).
+	MaxPortOffset = 99
+)
+
+// ClusterPorts is ports which components of a cluster listen on docker
+// host. Components use host network, so each cluster has its own ports
+// to run at the same time with other clusters on the same docker daemon.
+// Embedded k8s.yml and the version catalog refer them (e.g., {{.APIServerPort}}).
+type ClusterPorts struct {
+	APIServerPort         int
+	EtcdPort              int
+	EtcdPeerPort          int
+	KubeletPort           int
+	KubeletReadOnlyPort   int
+	KubeletHealthzPort    int
+	CAdvisorPort          int
+	ProxyHealthzPort      int
+	SchedulerPort         int
+	ControllerManagerPort int
+}
+
+// DefaultPorts is the default ports of each component. The default
+// cluster uses them as they are, so kubectl can connect to it without
+// configuration (localhost:8080).
+var DefaultPorts = ClusterPorts{
+	APIServerPort:         8080,
+	EtcdPort:              4001,
+	EtcdPeerPort:          7001,
+	KubeletPort:           10250,
+	KubeletReadOnlyPort:   10255,
+	KubeletHealthzPort:    10248,
+	CAdvisorPort:          4194,
+	ProxyHealthzPort:      10249,
+	SchedulerPort:         10251,
+	ControllerManagerPort: 10252,
+}
+
+// clusterPorts returns ports of the cluster whose port offset is offset.
+func clusterPorts(offset int) ClusterPorts {
+	shift := offset * PortOffsetStep
+	p := DefaultPorts
+	p.APIServerPort += shift
+	p.EtcdPort += shift
+	p.EtcdPeerPort += shift
+	p.KubeletPort += shift
+	p.KubeletReadOnlyPort += shift
+	p.KubeletHealthzPort += shift
+	p.CAdvisorPort += shift
+	p.ProxyHealthzPort += shift
+	p.SchedulerPort += shift
+	p.ControllerManagerPort += shift
+	return p
+}
+
+// LocalServer returns local address where port forwarding server
+// listens for API server. It's the same port as the remote one.
+func (p ClusterPorts) LocalServer() string {
+	return net.JoinHostPort("localhost", strconv.Itoa(p.APIServerPort))
+}
+
+// RemoteServer returns address of API server from the view of docker
+// host (e.g., via SSH tunnel).
+func (p ClusterPorts) RemoteServer() string {
+	return net.JoinHostPort("localhost", strconv.Itoa(p.APIServerPort))
+}
+
+// loadClusterPorts returns ports of the cluster which are recorded in
+// its state. If the cluster has no state (e.g., created by older boot2k8s,
+// which runs any cluster on the default ports), DefaultPorts is returned.
+func loadClusterPorts(name string) (ClusterPorts, error) {
+	state, err := LoadClusterState(name)
+	if err != nil {
+		return ClusterPorts{}, err
+	}
+
+	if state == nil {
+		return DefaultPorts, nil
+	}
+	return clusterPorts(state.PortOffset), nil
+}
+
+// allocatePortOffset returns port offset for the cluster. If the cluster
+// already has state, its offset is kept. Otherwise, the default cluster
+// uses 0 (DefaultPorts) and the named cluster uses the smallest offset
+// which no other cluster has (0 is reserved for the default cluster).
+func allocatePortOffset(name string) (int, error) {
+	states, err := listClusterStates()
+	if err != nil {
+		return 0, err
+	}
+
+	used := map[int]bool{0: true}
+	for _, state := range states {
+		if state.Name == name {
+			return state.PortOffset, nil
+		}
+		used[state.PortOffset] = true
+	}
+
+	if name == DefaultName {
+		return 0, nil
+	}
+
+	for offset := 1; offset <= MaxPortOffset; offset++ {
+		if !used[offset] {
+			return offset, nil
+		}
+	}
+
+	return 0, fmt.Errorf("all ports are used by other clusters (at most %d clusters)", MaxPortOffset+1)
+}
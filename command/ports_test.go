@@ -0,0 +1,80 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+func TestClusterPorts(t *testing.T) {
+	if got := clusterPorts(0); got != DefaultPorts {
+		t.Fatalf("expect %#v to eq %#v", got, DefaultPorts)
+	}
+
+	ports := clusterPorts(2)
+	if ports.APIServerPort != 8280 || ports.EtcdPort != 4201 || ports.KubeletPort != 10450 {
+		t.Fatalf("expect ports to be shifted by 200: %#v", ports)
+	}
+
+	if got, want := ports.LocalServer(), "localhost:8280"; got != want {
+		t.Fatalf("expect %q to eq %q", got, want)
+	}
+}
+
+func TestAllocatePortOffset(t *testing.T) {
+	home, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	for _, state := range []*ClusterState{
+		{Name: DefaultName},
+		{Name: "dev", PortOffset: 1},
+		{Name: "old", PortOffset: 3},
+	} {
+		if err := state.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		expect int
+	}{
+		{DefaultName, 0},
+		{"dev", 1},
+		{"old", 3},
+
+		// The smallest offset which no other cluster has
+		{"new", 2},
+	}
+
+	for _, tc := range cases {
+		offset, err := allocatePortOffset(tc.name)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if offset != tc.expect {
+			t.Fatalf("%s: expect %d to eq %d", tc.name, offset, tc.expect)
+		}
+	}
+
+	// Cluster without state uses the default ports
+	ports, err := loadClusterPorts("unknown")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if ports != DefaultPorts {
+		t.Fatalf("expect %#v to eq %#v", ports, DefaultPorts)
+	}
+}
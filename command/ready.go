@@ -0,0 +1,260 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/samalba/dockerclient"
+	"golang.org/x/crypto/ssh"
+)
+
+// NodeName is name of node registered by kubelet.
+// It's set via kubelet --hostname_override flag.
+const NodeName = "127.0.0.1"
+
+// httpClient is used for requesting kubernetes API server.
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// readinessCheck is a check which must pass before the cluster is ready.
+// Check returns nil when it passes.
+type readinessCheck struct {
+	Name  string
+	Check func() error
+}
+
+// dockerHost returns host where docker daemon runs. Since all components
+// run with host networking, they listen on this host. If docker runs on
+// remote host (e.g., boot2docker), it's extracted from DOCKER_HOST.
+func dockerHost() string {
+	u, err := url.Parse(os.Getenv("DOCKER_HOST"))
+	if err != nil || u.Scheme != "tcp" {
+		return "localhost"
+	}
+
+	host, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+	}
+
+	return host
+}
+
+// apiServerAddr returns address of kubernetes API server of the
+// cluster which listens on ports.
+func apiServerAddr(ports ClusterPorts) string {
+	return net.JoinHostPort(dockerHost(), strconv.Itoa(ports.APIServerPort))
+}
+
+// clusterProbe requests health check endpoints of cluster components
+// which listen on docker host.
+type clusterProbe struct {
+	// Client requests components and Host is host which they listen on
+	// from the view of Client (e.g., localhost via SSH tunnel).
+	Client *http.Client
+	Host   string
+
+	// SSH and Auth are settings of SSH tunnel which Client uses.
+	// They are nil when components are requested directly.
+	SSH  *SSHConfig
+	Auth []ssh.AuthMethod
+}
+
+// newClusterProbe returns clusterProbe for docker daemon (DOCKER_HOST).
+// If docker runs on remote host (e.g., boot2docker VM), components such
+// as API server only listen on localhost there, so they are requested
+// via SSH tunnel unless port forwarding is disabled by forwardMode.
+// Returned function closes the tunnel (and connection to ssh-agent).
+func newClusterProbe(ui cli.Ui, logger *log.Logger, sshFlags SSHConfig, forwardMode string) (*clusterProbe, func(), error) {
+	if dockerIsLocal(os.Getenv("DOCKER_HOST")) || forwardMode == ForwardNever {
+		return &clusterProbe{Client: httpClient, Host: dockerHost()}, func() {}, nil
+	}
+
+	sshConfig, err := resolveSSHConfig(sshFlags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read SSH settings: %s", err)
+	}
+
+	auth, err := sshConfig.AuthMethods(ui)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to construct SSH auth method: %s", err)
+	}
+
+	tunnel, err := connectSSHTunnel(logger, sshConfig, auth)
+	if err != nil {
+		sshConfig.Close()
+		return nil, nil, err
+	}
+
+	probe := &clusterProbe{
+		Client: &http.Client{
+			Timeout:   httpClient.Timeout,
+			Transport: &http.Transport{Dial: tunnel.Dial},
+		},
+		Host: "localhost",
+		SSH:  sshConfig,
+		Auth: auth,
+	}
+	return probe, func() {
+		tunnel.Close()
+		sshConfig.Close()
+	}, nil
+}
+
+// Get requests path of the component which listens on port.
+func (p *clusterProbe) Get(port int, path string) ([]byte, error) {
+	return httpGetWith(p.Client, net.JoinHostPort(p.Host, strconv.Itoa(port)), path)
+}
+
+// clusterReadinessChecks returns checks to detect the named cluster
+// whose components listen on ports is ready. They are expected to be
+// run in order.
+func clusterReadinessChecks(client dockerclient.Client, name string, ports ClusterPorts, probe *clusterProbe) []readinessCheck {
+	return []readinessCheck{
+		{
+			Name: "Master containers are created",
+			Check: func() error {
+				for _, service := range []string{ServiceAPIServer, ServiceControllerManager, ServiceScheduler, ServiceMaster} {
+					containers, err := listServiceContainers(client, name, service)
+					if err != nil {
+						return fmt.Errorf("failed to list containers: %s", err)
+					}
+
+					if !hasRunning(containers) {
+						return fmt.Errorf("no %s container is running", service)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name: "API server is healthy",
+			Check: func() error {
+				body, err := probe.Get(ports.APIServerPort, "/healthz")
+				if err != nil {
+					return err
+				}
+
+				if strings.TrimSpace(string(body)) != "ok" {
+					return fmt.Errorf("healthz returns %q", body)
+				}
+				return nil
+			},
+		},
+		{
+			Name: fmt.Sprintf("Node %s is registered", NodeName),
+			Check: func() error {
+				_, err := probe.Get(ports.APIServerPort, "/api/v1/nodes/"+NodeName)
+				return err
+			},
+		},
+		{
+			// API server checks them only on the default ports
+			// (componentstatuses), so they are checked directly.
+			Name: "Scheduler and controller-manager are healthy",
+			Check: func() error {
+				for _, port := range []int{ports.SchedulerPort, ports.ControllerManagerPort} {
+					if _, err := probe.Get(port, "/healthz"); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// afterClusterReady runs checks in order and then sends the struct{} on
+// the returned channel. Each check is retried until it passes and reported
+// via ui when passed. Retry interval starts from interval and is doubled
+// on each failure (see nextCheckInterval). Closing stopCh stops checking.
+func afterClusterReady(ui cli.Ui, logger *log.Logger, checks []readinessCheck, interval time.Duration, stopCh chan struct{}) chan struct{} {
+	doneCh := make(chan struct{})
+
+	go func() {
+		fmt.Fprintf(os.Stderr, "Wait until cluster is ready")
+		for _, check := range checks {
+			wait := interval
+			for {
+				select {
+				case <-stopCh:
+					return
+				case <-time.After(wait):
+				}
+
+				fmt.Fprintf(os.Stderr, ".")
+				err := check.Check()
+				if err == nil {
+					break
+				}
+
+				wait = nextCheckInterval(wait, interval)
+				logger.Printf("[DEBUG] %s: %s (retry in %s)", check.Name, err, wait)
+			}
+
+			fmt.Fprintf(os.Stderr, "\n")
+			ui.Output(fmt.Sprintf("  [OK] %s", check.Name))
+		}
+
+		select {
+		case doneCh <- struct{}{}:
+		case <-stopCh:
+		}
+	}()
+
+	return doneCh
+}
+
+// nextInterval returns doubled interval (exponential backoff).
+// It's never longer than MaxCheckInterval.
+func nextInterval(interval time.Duration) time.Duration {
+	interval *= 2
+	if interval > MaxCheckInterval {
+		interval = MaxCheckInterval
+	}
+	return interval
+}
+
+// nextCheckInterval returns next interval of readiness check. It's
+// capped at MaxCheckInterval, but never shorter than the initial interval
+// (e.g., -poll-interval=1m is kept as it is).
+func nextCheckInterval(wait, interval time.Duration) time.Duration {
+	if next := nextInterval(wait); next > interval {
+		return next
+	}
+	return interval
+}
+
+// httpGet sends GET request to the given server and returns its body.
+// If status code is not 200, it returns error.
+func httpGet(server, path string) ([]byte, error) {
+	return httpGetWith(httpClient, server, path)
+}
+
+// httpGetWith is httpGet which uses the given client.
+func httpGetWith(client *http.Client, server, path string) ([]byte, error) {
+	res, err := client.Get(fmt.Sprintf("http://%s%s", server, path))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returns %s", path, res.Status)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,89 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAPIServerAddr(t *testing.T) {
+	defer os.Setenv("DOCKER_HOST", os.Getenv("DOCKER_HOST"))
+
+	cases := []struct {
+		dockerHost string
+		expect     string
+	}{
+		{"", "localhost:8080"},
+		{"unix:///var/run/docker.sock", "localhost:8080"},
+		{"tcp://192.168.59.103:2376", "192.168.59.103:8080"},
+	}
+
+	for _, tc := range cases {
+		os.Setenv("DOCKER_HOST", tc.dockerHost)
+		if got := apiServerAddr(DefaultPorts); got != tc.expect {
+			t.Fatalf("expect %q to eq %q", got, tc.expect)
+		}
+	}
+}
+
+// testClusterProbe returns clusterProbe whose requests to any component
+// reach ts (as requests via SSH tunnel reach docker host).
+func testClusterProbe(ts *httptest.Server) *clusterProbe {
+	return &clusterProbe{
+		Client: &http.Client{
+			Transport: &http.Transport{
+				Dial: func(network, addr string) (net.Conn, error) {
+					return net.Dial(network, ts.Listener.Addr().String())
+				},
+			},
+		},
+		Host: "localhost",
+	}
+}
+
+func TestClusterProbe_Get(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	body, err := testClusterProbe(ts).Get(DefaultPorts.APIServerPort, "/healthz")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got, want := string(body), "localhost:8080 /healthz"; got != want {
+		t.Fatalf("expect %q to eq %q", got, want)
+	}
+}
+
+func TestNextInterval(t *testing.T) {
+	if got, want := nextInterval(3*time.Second), 6*time.Second; got != want {
+		t.Fatalf("expect %s to eq %s", got, want)
+	}
+
+	if got, want := nextInterval(20*time.Second), MaxCheckInterval; got != want {
+		t.Fatalf("expect %s to eq %s", got, want)
+	}
+}
+
+func TestNextCheckInterval(t *testing.T) {
+	cases := []struct {
+		wait, interval time.Duration
+		expect         time.Duration
+	}{
+		{3 * time.Second, 3 * time.Second, 6 * time.Second},
+		{20 * time.Second, 3 * time.Second, MaxCheckInterval},
+		{time.Minute, time.Minute, time.Minute},
+	}
+
+	for i, tc := range cases {
+		if got := nextCheckInterval(tc.wait, tc.interval); got != tc.expect {
+			t.Fatalf("#%d expect %s to eq %s", i, got, tc.expect)
+		}
+	}
+}
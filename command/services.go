@@ -0,0 +1,256 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// kubeService is kubernetes Service object. Only fields which are
+// needed to find NodePorts are defined.
+type kubeService struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		Ports []struct {
+			Name     string `json:"name"`
+			Protocol string `json:"protocol"`
+			Port     int    `json:"port"`
+			NodePort int    `json:"nodePort"`
+		} `json:"ports"`
+	} `json:"spec"`
+}
+
+// kubeServiceList is response of listing Services.
+type kubeServiceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []kubeService `json:"items"`
+}
+
+// kubeServiceEvent is event of watching Services.
+type kubeServiceEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Key returns namespace/name of the service.
+func (s *kubeService) Key() string {
+	return s.Metadata.Namespace + "/" + s.Metadata.Name
+}
+
+// NodePorts returns TCP NodePorts of the service by its display name
+// (e.g., default/nginx or default/nginx:http if it has multiple ports).
+func (s *kubeService) NodePorts() map[string]int {
+	ports := make(map[string]int)
+	for _, p := range s.Spec.Ports {
+		if p.NodePort == 0 || (p.Protocol != "" && p.Protocol != "TCP") {
+			continue
+		}
+
+		name := s.Key()
+		if len(s.Spec.Ports) > 1 {
+			if p.Name != "" {
+				name += ":" + p.Name
+			} else {
+				name += ":" + strconv.Itoa(p.Port)
+			}
+		}
+		ports[name] = p.NodePort
+	}
+	return ports
+}
+
+// serviceWatcher watches Services via API server and calls Open for
+// each NodePort which appears and Close for each NodePort which
+// disappears.
+type serviceWatcher struct {
+	Logger *log.Logger
+
+	// Client is used to request APIServer (e.g., via SSH tunnel).
+	Client    *http.Client
+	APIServer string
+
+	Open  func(name string, port int) error
+	Close func(name string, port int)
+
+	// services is NodePorts which are opened by service key.
+	services map[string]map[string]int
+}
+
+// Run watches Services until doneCh is closed. When watching fails
+// (e.g., API server is not ready yet), it retries with backoff.
+// All NodePorts are closed before returning.
+func (w *serviceWatcher) Run(doneCh chan struct{}) {
+	w.services = make(map[string]map[string]int)
+	defer func() {
+		for key := range w.services {
+			w.update(key, nil)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-doneCh
+		cancel()
+	}()
+
+	interval := DefaultReconnectInterval
+	for {
+		resourceVersion, err := w.list(ctx)
+		if err == nil {
+			interval = DefaultReconnectInterval
+			err = w.watch(ctx, resourceVersion)
+		}
+
+		if err != nil {
+			w.Logger.Printf("[DEBUG] Failed to watch services: %s (retry in %s)", err, interval)
+		}
+
+		select {
+		case <-doneCh:
+			return
+		case <-time.After(interval):
+		}
+
+		if err != nil {
+			interval = nextInterval(interval)
+		}
+	}
+}
+
+// list lists all Services and syncs NodePorts with them.
+// It returns resourceVersion to start watching from.
+func (w *serviceWatcher) list(ctx context.Context) (string, error) {
+	res, err := w.get(ctx, "/api/v1/services")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var list kubeServiceList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return "", err
+	}
+
+	seen := make(map[string]bool)
+	for _, svc := range list.Items {
+		seen[svc.Key()] = true
+		w.update(svc.Key(), svc.NodePorts())
+	}
+
+	for key := range w.services {
+		if !seen[key] {
+			w.update(key, nil)
+		}
+	}
+
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watch applies events of Services until the stream is closed.
+func (w *serviceWatcher) watch(ctx context.Context, resourceVersion string) error {
+	res, err := w.get(ctx, "/api/v1/services?watch=true&resourceVersion="+resourceVersion)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	decoder := json.NewDecoder(res.Body)
+	for {
+		var event kubeServiceEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if event.Type == "ERROR" {
+			// e.g., resourceVersion is too old. Start over from listing.
+			return fmt.Errorf("watch error: %s", event.Object)
+		}
+
+		var svc kubeService
+		if err := json.Unmarshal(event.Object, &svc); err != nil {
+			return err
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			w.update(svc.Key(), svc.NodePorts())
+		case "DELETED":
+			w.update(svc.Key(), nil)
+		}
+	}
+}
+
+func (w *serviceWatcher) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s%s", w.APIServer, path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := w.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("GET %s returns %s", path, res.Status)
+	}
+
+	return res, nil
+}
+
+// update opens and closes NodePorts of the service, so they are
+// same as ports. If ports is nil, all NodePorts of the service are closed.
+func (w *serviceWatcher) update(key string, ports map[string]int) {
+	current := w.services[key]
+	for name, port := range current {
+		if ports[name] != port {
+			w.Close(name, port)
+			w.Logger.Printf("[INFO] %s -> localhost:%d (closed)", name, port)
+			delete(current, name)
+		}
+	}
+
+	for name, port := range ports {
+		if current[name] == port {
+			continue
+		}
+
+		if err := w.Open(name, port); err != nil {
+			w.Logger.Printf("[ERROR] Failed to forward %s: %s", name, err)
+			continue
+		}
+		w.Logger.Printf("[INFO] %s -> localhost:%d", name, port)
+
+		if current == nil {
+			current = make(map[string]int)
+		}
+		current[name] = port
+	}
+
+	if len(current) == 0 {
+		delete(w.services, key)
+		return
+	}
+	w.services[key] = current
+}
+
+// nodePortListener is local server which forwards a NodePort.
+type nodePortListener struct {
+	net.Listener
+	stopCh chan struct{}
+}
@@ -0,0 +1,97 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServiceWatcher(t *testing.T) {
+	// API server which lists one service and then sends events
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "true" {
+			fmt.Fprint(w, `{"metadata": {"resourceVersion": "10"}, "items": [
+  {"metadata": {"name": "nginx", "namespace": "default"},
+   "spec": {"ports": [{"port": 80, "nodePort": 30080}]}},
+  {"metadata": {"name": "kubernetes", "namespace": "default"},
+   "spec": {"ports": [{"port": 443}]}}
+]}`)
+			return
+		}
+
+		if got := r.URL.Query().Get("resourceVersion"); got != "10" {
+			t.Errorf("expect %q to eq %q", got, "10")
+		}
+
+		fmt.Fprint(w, `{"type": "ADDED", "object": {"metadata": {"name": "web", "namespace": "dev"},
+  "spec": {"ports": [{"name": "http", "port": 80, "nodePort": 30081}, {"name": "dns", "protocol": "UDP", "port": 53, "nodePort": 30053}]}}}
+{"type": "DELETED", "object": {"metadata": {"name": "nginx", "namespace": "default"},
+  "spec": {"ports": [{"port": 80, "nodePort": 30080}]}}}
+`)
+		w.(http.Flusher).Flush()
+
+		// Keep watching until client stops
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var events []string
+	record := func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf(format, a...))
+	}
+
+	w := &serviceWatcher{
+		Logger:    log.New(ioutil.Discard, "", 0),
+		Client:    http.DefaultClient,
+		APIServer: ts.Listener.Addr().String(),
+		Open: func(name string, port int) error {
+			record("open %s %d", name, port)
+			return nil
+		},
+		Close: func(name string, port int) {
+			record("close %s %d", name, port)
+		},
+	}
+
+	doneCh := make(chan struct{})
+	finishCh := make(chan struct{})
+	go func() {
+		w.Run(doneCh)
+		close(finishCh)
+	}()
+
+	want := []string{
+		"open default/nginx 30080",
+		"open dev/web:http 30081",
+		"close default/nginx 30080",
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= len(want) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(doneCh)
+	<-finishCh
+
+	// Remaining ports are closed when watcher stops
+	want = append(want, "close dev/web:http 30081")
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("expect %v to eq %v", events, want)
+	}
+}
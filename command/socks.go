@@ -0,0 +1,156 @@
+package command
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol constants (RFC 1928). Only CONNECT command
+// without authentication is supported.
+const (
+	socksVersion = 0x05
+
+	socksMethodNoAuth       = 0x00
+	socksMethodNoAcceptable = 0xff
+
+	socksCmdConnect = 0x01
+
+	socksAddrIPv4   = 0x01
+	socksAddrDomain = 0x03
+	socksAddrIPv6   = 0x04
+
+	socksReplySucceeded           = 0x00
+	socksReplyGeneralFailure      = 0x01
+	socksReplyCommandNotSupported = 0x07
+	socksReplyAddrNotSupported    = 0x08
+)
+
+// socks handles SOCKS5 connection. Connection to the requested address
+// is dialed by dial (e.g., via SSH connection), so clients can reach
+// anything which docker host can reach (e.g., pod IPs). Connection is
+// counted in stats.
+func (s *PortForwardServer) socks(localConn net.Conn, dial dialFunc, stats *connStats) {
+	defer localConn.Close()
+	defer stats.connect()()
+
+	addr, err := socksHandshake(localConn)
+	if err != nil {
+		stats.failed()
+		s.Logger.Printf("[ERROR] Failed SOCKS handshake with %s: %s", localConn.RemoteAddr(), err)
+		return
+	}
+
+	start := time.Now()
+	remoteConn, err := dial("tcp", addr)
+	stats.dialed(time.Since(start), err)
+	if err != nil {
+		s.Logger.Printf("[ERROR] Failed to establish connection with %s via SSH: %s", addr, err)
+		socksReply(localConn, socksReplyGeneralFailure)
+		return
+	}
+	s.Logger.Printf("[DEBUG] Establish SOCKS connection with %s", addr)
+
+	if err := socksReply(localConn, socksReplySucceeded); err != nil {
+		remoteConn.Close()
+		return
+	}
+
+	s.pipe(localConn, remoteConn, stats)
+}
+
+// socksHandshake negotiates method and reads CONNECT request.
+// It returns requested address (host:port).
+func socksHandshake(conn net.Conn) (string, error) {
+	// Version and methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+
+	method := byte(socksMethodNoAcceptable)
+	for _, m := range methods {
+		if m == socksMethodNoAuth {
+			method = socksMethodNoAuth
+		}
+	}
+
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+
+	if method == socksMethodNoAcceptable {
+		return "", fmt.Errorf("no acceptable authentication method")
+	}
+
+	// Request: version, command, reserved and address type
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+
+	if request[0] != socksVersion {
+		socksReply(conn, socksReplyGeneralFailure)
+		return "", fmt.Errorf("unsupported SOCKS version %d in request", request[0])
+	}
+
+	if request[1] != socksCmdConnect {
+		socksReply(conn, socksReplyCommandNotSupported)
+		return "", fmt.Errorf("unsupported command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		size := net.IPv4len
+		if request[3] == socksAddrIPv6 {
+			size = net.IPv6len
+		}
+
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socksAddrDomain:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return "", err
+		}
+
+		domain := make([]byte, size[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		socksReply(conn, socksReplyAddrNotSupported)
+		return "", fmt.Errorf("unsupported address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksReply sends reply to the request. Bound address is unknown
+// (it's on docker host), so it's always 0.0.0.0:0.
+func socksReply(conn net.Conn, reply byte) error {
+	_, err := conn.Write([]byte{socksVersion, reply, 0x00, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
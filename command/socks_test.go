@@ -0,0 +1,140 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPortForwardServer_socks(t *testing.T) {
+	echo := startEchoServer(t)
+	defer echo.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dial echo server for any address and record requested one
+	dialedCh := make(chan string, 1)
+	dial := func(network, addr string) (net.Conn, error) {
+		dialedCh <- addr
+		return net.Dial("tcp", echo.Addr().String())
+	}
+
+	s := &PortForwardServer{Logger: log.New(ioutil.Discard, "", 0)}
+	doneCh, errCh := make(chan struct{}), make(chan error)
+	go s.serve(l, func(conn net.Conn) {
+		s.socks(conn, dial, &connStats{})
+	}, doneCh, errCh)
+	defer func() {
+		close(doneCh)
+		l.Close()
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Negotiate no authentication
+	conn.Write([]byte{0x05, 0x01, 0x00})
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reply, []byte{0x05, 0x00}) {
+		t.Fatalf("expect %v to eq [5 0]", reply)
+	}
+
+	// CONNECT nginx.default.svc:80
+	domain := "nginx.default.svc"
+	req := append([]byte{0x05, 0x01, 0x00, 0x03, byte(len(domain))}, domain...)
+	conn.Write(append(req, 0x00, 0x50))
+
+	reply = make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply[1] != 0x00 {
+		t.Fatalf("expect %d to eq 0", reply[1])
+	}
+
+	if got := <-dialedCh; got != "nginx.default.svc:80" {
+		t.Fatalf("expect %q to eq %q", got, "nginx.default.svc:80")
+	}
+
+	conn.Write([]byte("hello"))
+	conn.(*net.TCPConn).CloseWrite()
+	buf, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("expect %q to eq %q", buf, "hello")
+	}
+}
+
+func TestSocksHandshake_unsupportedCommand(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := socksHandshake(server)
+		errCh <- err
+		server.Close()
+	}()
+
+	client.Write([]byte{0x05, 0x01, 0x00})
+	client.Read(make([]byte, 2))
+
+	// BIND (address is not read since command is rejected first)
+	client.Write([]byte{0x05, 0x02, 0x00, 0x01})
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(client, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply[1] != socksReplyCommandNotSupported {
+		t.Fatalf("expect %d to eq %d", reply[1], socksReplyCommandNotSupported)
+	}
+
+	if err := <-errCh; err == nil {
+		t.Fatal("expect BIND to fail")
+	}
+}
+
+func TestSocksHandshake_invalidRequestVersion(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := socksHandshake(server)
+		errCh <- err
+		server.Close()
+	}()
+
+	client.Write([]byte{0x05, 0x01, 0x00})
+	client.Read(make([]byte, 2))
+
+	// CONNECT with SOCKS4 version
+	client.Write([]byte{0x04, 0x01, 0x00, 0x01})
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(client, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply[1] != socksReplyGeneralFailure {
+		t.Fatalf("expect %d to eq %d", reply[1], socksReplyGeneralFailure)
+	}
+
+	if err := <-errCh; err == nil {
+		t.Fatal("expect invalid version to fail")
+	}
+}
@@ -0,0 +1,379 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Boot2docker related constants. They are default SSH settings.
+	// These constants are from github.com/boot2docker/boot2docker-cli
+	B2DSshKeyFile string = "id_boot2docker"
+	B2DSshHost    string = "localhost"
+	B2DSshPort    string = "2022"
+	B2DSshUser    string = "docker"
+
+	// Environmental variables to change SSH settings.
+	EnvSSHHost = "BOOT2K8S_SSH_HOST"
+	EnvSSHPort = "BOOT2K8S_SSH_PORT"
+	EnvSSHUser = "BOOT2K8S_SSH_USER"
+	EnvSSHKey  = "BOOT2K8S_SSH_KEY"
+
+	// EnvSSHAuthSock is socket of ssh-agent. If it's set, keys in the
+	// agent are also used for authentication.
+	EnvSSHAuthSock = "SSH_AUTH_SOCK"
+
+	// SettingsFileName is name of the settings file under StateDir.
+	SettingsFileName = "config.yml"
+
+	// KnownHostsFileName is name of known_hosts file under StateDir.
+	// It's separated from ~/.ssh/known_hosts, since boot2docker VM is
+	// recreated often with new host key on the same address.
+	KnownHostsFileName = "known_hosts"
+)
+
+// SSHConfig is settings of SSH connection for port forwarding.
+// Empty field means it's not set.
+type SSHConfig struct {
+	Host    string `yaml:"host"`
+	Port    string `yaml:"port"`
+	User    string `yaml:"user"`
+	KeyFile string `yaml:"key"`
+
+	// InsecureSkipHostKey disables host key verification. It's only
+	// set via -insecure-skip-host-key flag.
+	InsecureSkipHostKey bool `yaml:"-"`
+
+	// agentConn is connection to ssh-agent which AuthMethods opens.
+	// It's used while auth methods are used and closed by Close.
+	agentConn net.Conn
+}
+
+// Settings is content of the settings file (~/.boot2k8s/config.yml).
+type Settings struct {
+	SSH SSHConfig `yaml:"ssh"`
+}
+
+// loadSettings reads the settings file. If it doesn't exist,
+// it returns empty settings.
+func loadSettings() (*Settings, error) {
+	stateDir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(stateDir, SettingsFileName)
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Settings{}, nil
+		}
+		return nil, err
+	}
+
+	var settings Settings
+	if err := yaml.Unmarshal(buf, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+
+	return &settings, nil
+}
+
+// addSSHFlags adds flags to set SSH settings into c.
+func addSSHFlags(flags *flag.FlagSet, c *SSHConfig) {
+	flags.StringVar(&c.Host, "ssh-host", "", "")
+	flags.StringVar(&c.Port, "ssh-port", "", "")
+	flags.StringVar(&c.User, "ssh-user", "", "")
+	flags.StringVar(&c.KeyFile, "ssh-key", "", "")
+	flags.BoolVar(&c.InsecureSkipHostKey, "insecure-skip-host-key", false, "")
+}
+
+// Merge overrides fields by non-empty fields of other.
+func (c *SSHConfig) Merge(other SSHConfig) {
+	if other.Host != "" {
+		c.Host = other.Host
+	}
+	if other.Port != "" {
+		c.Port = other.Port
+	}
+	if other.User != "" {
+		c.User = other.User
+	}
+	if other.KeyFile != "" {
+		c.KeyFile = other.KeyFile
+	}
+	if other.InsecureSkipHostKey {
+		c.InsecureSkipHostKey = true
+	}
+}
+
+// Addr returns address of SSH server.
+func (c *SSHConfig) Addr() string {
+	return net.JoinHostPort(c.Host, c.Port)
+}
+
+// resolveSSHConfig returns SSH settings. They are taken from flags,
+// environmental variables, the settings file and boot2docker defaults
+// in that order of priority.
+func resolveSSHConfig(flags SSHConfig) (*SSHConfig, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &SSHConfig{
+		Host:    B2DSshHost,
+		Port:    B2DSshPort,
+		User:    B2DSshUser,
+		KeyFile: filepath.Join(home, ".ssh", B2DSshKeyFile),
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		return nil, err
+	}
+	c.Merge(settings.SSH)
+
+	c.Merge(SSHConfig{
+		Host:    os.Getenv(EnvSSHHost),
+		Port:    os.Getenv(EnvSSHPort),
+		User:    os.Getenv(EnvSSHUser),
+		KeyFile: os.Getenv(EnvSSHKey),
+	})
+
+	c.Merge(flags)
+
+	keyFile, err := homedir.Expand(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	c.KeyFile = keyFile
+
+	return c, nil
+}
+
+// AuthMethods returns ssh auth methods. It reads & parses the key
+// file (if the key is encrypted, passphrase is asked via ui) and also
+// uses ssh-agent if SSH_AUTH_SOCK is set. The key file can be missing
+// only when ssh-agent is available. Connection to ssh-agent is kept
+// until Close is called.
+func (c *SSHConfig) AuthMethods(ui cli.Ui) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	signer, keyErr := readPrivateKey(c.KeyFile, ui)
+	if keyErr == nil {
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if sock := os.Getenv(EnvSSHAuthSock); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect ssh-agent: %s", err)
+		}
+		c.Close()
+		c.agentConn = conn
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if keyErr != nil && (len(methods) == 0 || !os.IsNotExist(keyErr)) {
+		c.Close()
+		return nil, keyErr
+	}
+
+	return methods, nil
+}
+
+// Close closes connection to ssh-agent which AuthMethods opens.
+// Auth methods can't use ssh-agent after that.
+func (c *SSHConfig) Close() error {
+	if c.agentConn == nil {
+		return nil
+	}
+
+	err := c.agentConn.Close()
+	c.agentConn = nil
+	return err
+}
+
+// readPrivateKey reads & parses private key file. If the key is
+// encrypted, passphrase is asked via ui.
+func readPrivateKey(path string, ui cli.Ui) (ssh.Signer, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(buf)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		passphrase, askErr := ui.AskSecret(fmt.Sprintf("Enter passphrase for key %s:", path))
+		if askErr != nil {
+			return nil, askErr
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(buf, []byte(passphrase))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %s", path, err)
+	}
+
+	return signer, nil
+}
+
+// knownHostsPath returns path of known_hosts file of boot2k8s.
+func knownHostsPath() (string, error) {
+	stateDir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(stateDir, KnownHostsFileName), nil
+}
+
+// HostKeyCallback returns callback to verify host key of SSH server.
+// Host key is verified against boot2k8s known_hosts file. Key of unknown
+// host is trusted on first use and added to the file. If the host has
+// known keys and the key is none of them, connection is refused (server
+// is asked for known algorithms by HostKeyAlgorithms, so the key of
+// other algorithm is never trusted while the host is known).
+func (c *SSHConfig) HostKeyCallback(logger *log.Logger) (ssh.HostKeyCallback, error) {
+	if c.InsecureSkipHostKey {
+		logger.Printf("[WARN] Host key verification is disabled")
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	path, err := knownHostsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// knownhosts can't read file which doesn't exist
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		keyErr, ok := err.(*knownhosts.KeyError)
+		if !ok {
+			return err
+		}
+
+		// Known host presents other key
+		if len(keyErr.Want) > 0 {
+			known := keyErr.Want[0]
+			return fmt.Errorf(
+				"host key of %s has changed (got %s %s, but %s:%d has %s %s).\n"+
+					"Someone may be intercepting the connection, or the VM is recreated.\n"+
+					"If the VM is recreated, remove the line from %s and retry, or\n"+
+					"use -insecure-skip-host-key to skip verification",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), known.Filename, known.Line,
+				known.Key.Type(), ssh.FingerprintSHA256(known.Key), known.Filename)
+		}
+
+		// Unknown host, trust it on first use
+		if err := appendKnownHost(path, hostname, key); err != nil {
+			return fmt.Errorf("failed to add host key to %s: %s", path, err)
+		}
+		logger.Printf("[INFO] Add host key of %s (%s %s) to %s",
+			hostname, key.Type(), ssh.FingerprintSHA256(key), path)
+
+		// Re-read the file, so the next connection (e.g., reconnect) knows the key
+		callback, err = knownhosts.New(path)
+		return err
+	}, nil
+}
+
+// appendKnownHost adds host key line to known_hosts file.
+// HostKeyAlgorithms returns algorithms of host keys which are recorded
+// for SSH server in boot2k8s known_hosts file. Server which has keys of
+// several algorithms is asked to present the known one with them. For
+// unknown host (or when verification is skipped), it returns nil and
+// any algorithm is accepted.
+func (c *SSHConfig) HostKeyAlgorithms() ([]string, error) {
+	if c.InsecureSkipHostKey {
+		return nil, nil
+	}
+
+	path, err := knownHostsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	host := knownhosts.Normalize(c.Addr())
+	var algos []string
+	for {
+		marker, hosts, key, _, rest, err := ssh.ParseKnownHosts(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %s", path, err)
+		}
+		buf = rest
+
+		if marker != "" || !hasHost(hosts, host) {
+			continue
+		}
+
+		// RSA key can be used with SHA-2 signatures
+		if key.Type() == ssh.KeyAlgoRSA {
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algos = append(algos, key.Type())
+	}
+
+	return algos, nil
+}
+
+// hasHost returns true if hosts of known_hosts line has host.
+func hasHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if knownhosts.Normalize(h) == host {
+			return true
+		}
+	}
+	return false
+}
+
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
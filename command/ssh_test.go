@@ -0,0 +1,264 @@
+package command
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+)
+
+// passphraseUi answers passphrase to AskSecret.
+type passphraseUi struct {
+	cli.Ui
+	passphrase string
+}
+
+func (u *passphraseUi) AskSecret(query string) (string, error) {
+	return u.passphrase, nil
+}
+
+func TestResolveSSHConfig(t *testing.T) {
+	home, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	os.MkdirAll(filepath.Join(home, StateDirName), 0755)
+	ioutil.WriteFile(filepath.Join(home, StateDirName, SettingsFileName), []byte(`
+ssh:
+  host: 192.168.99.100
+  port: "22"
+  key: ~/.ssh/id_rsa
+`), 0644)
+
+	defer os.Setenv(EnvSSHPort, os.Getenv(EnvSSHPort))
+	os.Setenv(EnvSSHPort, "2222")
+
+	c, err := resolveSSHConfig(SSHConfig{User: "core"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &SSHConfig{
+		Host:    "192.168.99.100",
+		Port:    "2222",
+		User:    "core",
+		KeyFile: filepath.Join(home, ".ssh", "id_rsa"),
+	}
+	if *c != *want {
+		t.Fatalf("expect %#v to eq %#v", c, want)
+	}
+
+	if got := c.Addr(); got != "192.168.99.100:2222" {
+		t.Fatalf("expect %q to eq %q", got, "192.168.99.100:2222")
+	}
+}
+
+func TestReadPrivateKey_encrypted(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ioutil.TempFile("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(pem.EncodeToMemory(block))
+	f.Close()
+
+	if _, err := readPrivateKey(f.Name(), &passphraseUi{passphrase: "secret"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := readPrivateKey(f.Name(), &passphraseUi{passphrase: "wrong"}); err == nil {
+		t.Fatal("expect to fail with wrong passphrase")
+	}
+}
+
+func TestSSHConfig_AuthMethods_missingKey(t *testing.T) {
+	defer os.Setenv(EnvSSHAuthSock, os.Getenv(EnvSSHAuthSock))
+	os.Setenv(EnvSSHAuthSock, "")
+
+	c := &SSHConfig{KeyFile: filepath.Join(os.TempDir(), "boot2k8s-no-such-key")}
+	if _, err := c.AuthMethods(&passphraseUi{}); err == nil {
+		t.Fatal("expect to fail without key and ssh-agent")
+	}
+}
+
+func TestSSHConfig_Close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Stand-in of ssh-agent which only accepts connection
+	sock := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	defer os.Setenv(EnvSSHAuthSock, os.Getenv(EnvSSHAuthSock))
+	os.Setenv(EnvSSHAuthSock, sock)
+
+	c := &SSHConfig{KeyFile: filepath.Join(dir, "no-such-key")}
+	if _, err := c.AuthMethods(&passphraseUi{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Agent sees EOF when the connection is closed
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expect connection to ssh-agent to be closed")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("expect second Close to be no-op: %s", err)
+	}
+}
+
+func TestSSHConfig_HostKeyCallback(t *testing.T) {
+	home, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	newKey := func() ssh.PublicKey {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	logger := log.New(ioutil.Discard, "", 0)
+	remote := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2022}
+	key := newKey()
+
+	c := &SSHConfig{}
+	callback, err := c.HostKeyCallback(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trusted on first use and it's still trusted
+	for i := 0; i < 2; i++ {
+		if err := callback("localhost:2022", remote, key); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Recorded key is used by new callback
+	callback, err = c.HostKeyCallback(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := callback("localhost:2022", remote, key); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = callback("localhost:2022", remote, newKey())
+	if err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Fatalf("expect error for changed key: %v", err)
+	}
+
+	buf, _ := ioutil.ReadFile(filepath.Join(home, StateDirName, KnownHostsFileName))
+	if got := strings.Count(string(buf), "\n"); got != 1 {
+		t.Fatalf("expect %d to eq 1", got)
+	}
+
+	// Key of other algorithm is also refused while the host is known
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ssh.NewPublicKey(&ecdsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = callback("localhost:2022", remote, otherKey)
+	if err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Fatalf("expect error for key of other algorithm: %v", err)
+	}
+
+	buf, _ = ioutil.ReadFile(filepath.Join(home, StateDirName, KnownHostsFileName))
+	if got := strings.Count(string(buf), "\n"); got != 1 {
+		t.Fatalf("expect %d to eq 1", got)
+	}
+
+	// Server is asked for the algorithm of the known key
+	c.Host, c.Port = "localhost", "2022"
+	algos, err := c.HostKeyAlgorithms()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(algos) != 1 || algos[0] != ssh.KeyAlgoED25519 {
+		t.Fatalf("expect %v to eq [%s]", algos, ssh.KeyAlgoED25519)
+	}
+
+	// Any algorithm is accepted for unknown host
+	c.Port = "22"
+	if algos, err := c.HostKeyAlgorithms(); err != nil || algos != nil {
+		t.Fatalf("expect no algorithms for unknown host: %v, %v", algos, err)
+	}
+
+	// Verification can be skipped explicitly
+	c.InsecureSkipHostKey = true
+	callback, err = c.HostKeyCallback(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := callback("localhost:2022", remote, newKey()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
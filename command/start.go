@@ -0,0 +1,185 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/docker/libcompose/docker"
+)
+
+type StartCommand struct {
+	Meta
+}
+
+func (c *StartCommand) Run(args []string) int {
+
+	var insecure bool
+	var logLevel string
+	var configs stringSlice
+	var sshFlags SSHConfig
+	var timeout, pollInterval time.Duration
+	flags := c.Meta.flagSet("start")
+	flags.BoolVar(&insecure, "insecure", false, "")
+	flags.Var(&configs, "config", "")
+	flags.StringVar(&logLevel, "log-level", "info", "")
+	flags.DurationVar(&timeout, "timeout", DefaultCheckTimeOut, "")
+	flags.DurationVar(&pollInterval, "poll-interval", DefaultCheckInterval, "")
+	addSSHFlags(flags, &sshFlags)
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if timeout <= 0 || pollInterval <= 0 {
+		c.Ui.Error("-timeout and -poll-interval must be positive duration")
+		return 1
+	}
+
+	// Create logger with Log level
+	logger := newLogger(logLevel)
+
+	name := c.clusterName()
+	compose, err := clusterCompose(name, configs)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read compose file: %s", err))
+		return 1
+	}
+
+	// Set up docker client
+	clientFactory, err := docker.NewDefaultClientFactory(
+		docker.ClientOpts{
+			TLS: !insecure,
+		},
+	)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to construct Docker client: %s", err))
+		return 1
+	}
+
+	client := clientFactory.Create(nil)
+
+	// start only works for the cluster which is stopped by stop command.
+	containers, err := listServiceContainers(client, name, "")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to list containers: %s", err))
+		return 1
+	}
+
+	if len(containers) < 1 {
+		c.Ui.Error("There is no kubernetes cluster to start. Use `up` command instead")
+		return 1
+	}
+
+	state, err := LoadClusterState(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
+		return 1
+	}
+
+	// Cluster created by older boot2k8s has no state and uses the
+	// default ports.
+	var offset int
+	if state != nil {
+		offset = state.PortOffset
+	}
+
+	// Same as up, clusters which have the same ports can't run at
+	// the same time.
+	if !checkPortConflict(c.Ui, client, name, offset) {
+		return 1
+	}
+
+	// Components on docker VM only listen on localhost there, so
+	// readiness is checked via SSH tunnel.
+	probe, closeProbe, err := newClusterProbe(c.Ui, logger, sshFlags, ForwardAuto)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to connect to docker host: %s", err))
+		return 1
+	}
+	defer closeProbe()
+
+	// Setup new docker-compose project
+	project, err := newProject(name, compose, clientFactory)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to setup project: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Start kubernetes cluster %q!", name))
+
+	// kubelet restarts pod containers by itself.
+	if err := project.Start(); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to start containers: %s", err))
+		return 1
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+
+	// Stop checking cluster when returning (e.g., timeout)
+	stopCheckCh := make(chan struct{})
+	defer close(stopCheckCh)
+
+	checks := clusterReadinessChecks(client, name, clusterPorts(offset), probe)
+	select {
+	case <-afterClusterReady(c.Ui, logger, checks, pollInterval, stopCheckCh):
+		c.Ui.Info("Successfully start kubernetes cluster")
+	case <-sigCh:
+		c.Ui.Error("")
+		c.Ui.Error("Interrupted!")
+		c.Ui.Error("Check the containers are working with `boot2k8s status` command.")
+		return 1
+	case <-time.After(timeout):
+		c.Ui.Error("")
+		c.Ui.Error("Timeout happened while waiting cluster is ready.")
+		c.Ui.Error("Check the containers are working with `boot2k8s status`")
+		c.Ui.Error("and `boot2k8s logs` command.")
+		return 1
+	}
+
+	return 0
+}
+
+func (c *StartCommand) Synopsis() string {
+	return "Start kubernetes cluster which is stopped by stop command"
+}
+
+func (c *StartCommand) Help() string {
+	helpText := `Start kubernetes cluster which is stopped by "stop" command
+and wait until it's ready.
+
+Options:
+
+  -name=NAME      Name of the cluster. Default is "default".
+
+  -config=PATH    Compose file which is used for "up" (See "up -help").
+
+  -timeout=DUR    Timeout for waiting cluster is ready. Default is 5m.
+
+  -poll-interval=DUR
+                  Initial interval to check cluster is ready.
+                  Default is 3s.
+
+  -insecure       Allow insecure non-TLS connection to docker client.
+
+  -ssh-host, -ssh-port, -ssh-user, -ssh-key, -insecure-skip-host-key
+                  SSH settings (See "forward -help"). If docker runs on
+                  VM, readiness is checked via SSH connection, since API
+                  server only listens on localhost there.
+
+  -log-level      Log level (DEBUG, INFO, WARN, ERROR).
+                  Default is INFO.
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateFileName is file name of the cluster state.
+const StateFileName = "state.json"
+
+// ClusterState is information of the cluster which is recorded at up.
+// Other commands use it to act on the cluster in the same way (e.g.,
+// same compose files and kubernetes version).
+type ClusterState struct {
+	Name       string    `json:"name"`
+	Project    string    `json:"project"`
+	K8sVersion string    `json:"k8s_version"`
+	Configs    []string  `json:"configs,omitempty"`
+	DataDir    string    `json:"data_dir,omitempty"`
+	DockerHost string    `json:"docker_host,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// APIServer is address of API server which kubectl connects to.
+	// If docker runs on remote host, it's local end of port forwarding
+	// (see kubectlServer). Forwarded is true if up started port
+	// forwarding server.
+	APIServer string `json:"api_server"`
+	Forwarded bool   `json:"forwarded,omitempty"`
+
+	// PortOffset decides ports which components of the cluster listen
+	// on (see clusterPorts). It's 0 for the default cluster and the
+	// cluster created by older boot2k8s.
+	PortOffset int `json:"port_offset,omitempty"`
+
+	// KubeletRootDir is root directory of kubelet of the cluster. It
+	// identifies pod containers of the cluster. If empty (the cluster
+	// is created by older boot2k8s), they can't be distinguished from
+	// others, so they are skipped (see podRootDir).
+	KubeletRootDir string `json:"kubelet_root_dir,omitempty"`
+}
+
+// clusterDir returns directory where files of the cluster are stored.
+func clusterDir(name string) (string, error) {
+	stateDir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(stateDir, "clusters", name), nil
+}
+
+// LoadClusterState reads state of the cluster. If the cluster has
+// no state (e.g., not created yet), it returns nil without error.
+func LoadClusterState(name string) (*ClusterState, error) {
+	dir, err := clusterDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(dir, StateFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var state ClusterState
+	if err := json.Unmarshal(buf, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// listClusterStates returns states of all clusters.
+func listClusterStates() ([]*ClusterState, error) {
+	stateDir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(filepath.Join(stateDir, "clusters"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	states := make([]*ClusterState, 0, len(infos))
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+
+		state, err := LoadClusterState(info.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state of cluster %q: %s", info.Name(), err)
+		}
+
+		// e.g., only forward daemon files are left
+		if state != nil {
+			states = append(states, state)
+		}
+	}
+
+	return states, nil
+}
+
+// Save writes state to the cluster directory.
+func (s *ClusterState) Save() error {
+	dir, err := clusterDir(s.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, StateFileName), buf, 0644)
+}
+
+// RemoveClusterState removes state of the cluster.
+func RemoveClusterState(name string) error {
+	dir, err := clusterDir(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(dir, StateFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// clusterCompose returns compose of the existing cluster. Compose files,
+// kubernetes version, data directory and ports which are recorded at up
+// are used.
+// If configs is given, it's used instead of recorded compose files.
+func clusterCompose(name string, configs []string) ([]byte, error) {
+	state, err := LoadClusterState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var k8sVersion, dataDir string
+	var offset int
+	if state != nil {
+		k8sVersion, dataDir, offset = state.K8sVersion, state.DataDir, state.PortOffset
+		if len(configs) == 0 {
+			configs = state.Configs
+		}
+	}
+
+	params, err := newComposeParams(name, k8sVersion, offset)
+	if err != nil {
+		return nil, err
+	}
+	params.DataDir = dataDir
+
+	return loadCompose(configPaths(configs), params)
+}
@@ -0,0 +1,238 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStatsAddr is address of statistics endpoint which
+// "forward stats" reads by default.
+const DefaultStatsAddr = "localhost:8079"
+
+// ForwardStatsFileName is file under the cluster directory which port
+// forwarding server writes its statistics address to while it runs.
+const ForwardStatsFileName = "forward.stats"
+
+// writeForwardStatsAddr records addr as statistics address of port
+// forwarding server of the named cluster. It returns function to remove
+// the record when the server stops.
+func writeForwardStatsAddr(name, addr string) (func(), error) {
+	dir, err := clusterDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, ForwardStatsFileName)
+	if err := ioutil.WriteFile(path, []byte(addr+"\n"), 0644); err != nil {
+		return nil, err
+	}
+
+	return func() { os.Remove(path) }, nil
+}
+
+// forwardStatsAddr returns statistics address of port forwarding server
+// of the named cluster. If it's not recorded (e.g., the server runs
+// without -stats-addr), DefaultStatsAddr is returned.
+func forwardStatsAddr(name string) (string, error) {
+	dir, err := clusterDir(name)
+	if err != nil {
+		return "", err
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(dir, ForwardStatsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultStatsAddr, nil
+		}
+		return "", err
+	}
+
+	if addr := strings.TrimSpace(string(buf)); addr != "" {
+		return addr, nil
+	}
+	return DefaultStatsAddr, nil
+}
+
+// connStats is statistics of connections of a mapping (e.g., -L mapping,
+// NodePort or SOCKS proxy). Counters are updated atomically, since
+// connections are handled in their own goroutines.
+type connStats struct {
+	active        int64
+	total         int64
+	bytesSent     int64
+	bytesReceived int64
+	dialCount     int64
+	dialNanos     int64
+	errors        int64
+}
+
+// connect counts new connection. It returns function to call when
+// the connection is closed.
+func (c *connStats) connect() func() {
+	atomic.AddInt64(&c.total, 1)
+	atomic.AddInt64(&c.active, 1)
+	return func() {
+		atomic.AddInt64(&c.active, -1)
+	}
+}
+
+// dialed records latency of dialing remote server. Failure is counted
+// as error.
+func (c *connStats) dialed(latency time.Duration, err error) {
+	if err != nil {
+		c.failed()
+		return
+	}
+	atomic.AddInt64(&c.dialCount, 1)
+	atomic.AddInt64(&c.dialNanos, int64(latency))
+}
+
+// failed counts error of the mapping (e.g., dial or transfer failure).
+func (c *connStats) failed() {
+	atomic.AddInt64(&c.errors, 1)
+}
+
+// countWriter counts bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+// MappingStats is snapshot of statistics of a mapping. Sent is bytes
+// from local to remote, and Received is bytes from remote to local.
+type MappingStats struct {
+	Name          string  `json:"name"`
+	Active        int64   `json:"active"`
+	Total         int64   `json:"total"`
+	BytesSent     int64   `json:"bytes_sent"`
+	BytesReceived int64   `json:"bytes_received"`
+	DialCount     int64   `json:"dial_count"`
+	DialSeconds   float64 `json:"dial_seconds"`
+	Errors        int64   `json:"errors"`
+}
+
+// DialLatency returns average latency of dialing remote server.
+func (m MappingStats) DialLatency() time.Duration {
+	if m.DialCount == 0 {
+		return 0
+	}
+	return time.Duration(m.DialSeconds / float64(m.DialCount) * float64(time.Second))
+}
+
+// forwardStats is statistics of all mappings of port forwarding server.
+type forwardStats struct {
+	mu       sync.Mutex
+	names    []string
+	mappings map[string]*connStats
+}
+
+func newForwardStats() *forwardStats {
+	return &forwardStats{mappings: make(map[string]*connStats)}
+}
+
+// Mapping returns statistics of the mapping which has name. It's created
+// on the first call and kept (e.g., after NodePort is closed).
+func (s *forwardStats) Mapping(name string) *connStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.mappings[name]; ok {
+		return c
+	}
+
+	c := &connStats{}
+	s.mappings[name] = c
+	s.names = append(s.names, name)
+	return c
+}
+
+// Snapshot returns current statistics in order of creation.
+func (s *forwardStats) Snapshot() []MappingStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make([]MappingStats, 0, len(s.names))
+	for _, name := range s.names {
+		c := s.mappings[name]
+		snapshot = append(snapshot, MappingStats{
+			Name:          name,
+			Active:        atomic.LoadInt64(&c.active),
+			Total:         atomic.LoadInt64(&c.total),
+			BytesSent:     atomic.LoadInt64(&c.bytesSent),
+			BytesReceived: atomic.LoadInt64(&c.bytesReceived),
+			DialCount:     atomic.LoadInt64(&c.dialCount),
+			DialSeconds:   time.Duration(atomic.LoadInt64(&c.dialNanos)).Seconds(),
+			Errors:        atomic.LoadInt64(&c.errors),
+		})
+	}
+	return snapshot
+}
+
+// Handler returns HTTP handler which serves statistics as JSON on
+// /stats and as Prometheus text format on /metrics.
+func (s *forwardStats) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Snapshot())
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writePrometheus(w, s.Snapshot())
+	})
+	return mux
+}
+
+// writePrometheus writes statistics in Prometheus text format.
+func writePrometheus(w io.Writer, snapshot []MappingStats) {
+	metrics := []struct {
+		name, kind, help string
+		value            func(m MappingStats) string
+	}{
+		{"boot2k8s_forward_active_connections", "gauge", "Number of active connections.",
+			func(m MappingStats) string { return fmt.Sprint(m.Active) }},
+		{"boot2k8s_forward_connections_total", "counter", "Number of accepted connections.",
+			func(m MappingStats) string { return fmt.Sprint(m.Total) }},
+		{"boot2k8s_forward_sent_bytes_total", "counter", "Bytes transferred from local to remote.",
+			func(m MappingStats) string { return fmt.Sprint(m.BytesSent) }},
+		{"boot2k8s_forward_received_bytes_total", "counter", "Bytes transferred from remote to local.",
+			func(m MappingStats) string { return fmt.Sprint(m.BytesReceived) }},
+		{"boot2k8s_forward_dial_seconds_total", "counter", "Total time to dial remote server.",
+			func(m MappingStats) string { return fmt.Sprint(m.DialSeconds) }},
+		{"boot2k8s_forward_dials_total", "counter", "Number of successful dials to remote server.",
+			func(m MappingStats) string { return fmt.Sprint(m.DialCount) }},
+		{"boot2k8s_forward_errors_total", "counter", "Number of failed dials and transfers.",
+			func(m MappingStats) string { return fmt.Sprint(m.Errors) }},
+	}
+
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", metric.name, metric.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", metric.name, metric.kind)
+		for _, m := range snapshot {
+			fmt.Fprintf(w, "%s{mapping=\"%s\"} %s\n",
+				metric.name, labelEscaper.Replace(m.Name), metric.value(m))
+		}
+	}
+}
+
+// labelEscaper escapes label value of Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
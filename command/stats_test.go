@@ -0,0 +1,179 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/go-homedir"
+)
+
+func TestForwardStats(t *testing.T) {
+	stats := newForwardStats()
+
+	c := stats.Mapping("localhost:8080:localhost:8080")
+	disconnect := c.connect()
+	c.dialed(2*time.Second, nil)
+	c.dialed(0, errors.New("connection refused"))
+	countWriter{&bytes.Buffer{}, &c.bytesSent}.Write([]byte("hello"))
+
+	if stats.Mapping("localhost:8080:localhost:8080") != c {
+		t.Fatal("expect the same stats for the same mapping")
+	}
+	stats.Mapping("default/nginx").connect()()
+
+	got := stats.Snapshot()
+	want := MappingStats{
+		Name:        "localhost:8080:localhost:8080",
+		Active:      1,
+		Total:       1,
+		BytesSent:   5,
+		DialCount:   1,
+		DialSeconds: 2,
+		Errors:      1,
+	}
+	if len(got) != 2 || got[0] != want {
+		t.Fatalf("expect %#v to eq %#v", got, want)
+	}
+
+	if got[1].Active != 0 || got[1].Total != 1 {
+		t.Fatalf("expect active 0 and total 1: %#v", got[1])
+	}
+
+	if got[0].DialLatency() != 2*time.Second {
+		t.Fatalf("expect %s to eq %s", got[0].DialLatency(), 2*time.Second)
+	}
+
+	disconnect()
+	if got := stats.Snapshot()[0].Active; got != 0 {
+		t.Fatalf("expect %d to eq 0", got)
+	}
+}
+
+func TestForwardStats_Handler(t *testing.T) {
+	stats := newForwardStats()
+	stats.Mapping(`default/"nginx"`).connect()
+
+	server := httptest.NewServer(stats.Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var snapshot []MappingStats
+	if err := json.NewDecoder(res.Body).Decode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot) != 1 || snapshot[0].Active != 1 {
+		t.Fatalf("expect one active connection: %#v", snapshot)
+	}
+
+	var buf bytes.Buffer
+	writePrometheus(&buf, snapshot)
+	for _, line := range []string{
+		"# TYPE boot2k8s_forward_active_connections gauge",
+		`boot2k8s_forward_active_connections{mapping="default/\"nginx\""} 1`,
+		`boot2k8s_forward_connections_total{mapping="default/\"nginx\""} 1`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("expect %q to contain %q", buf.String(), line)
+		}
+	}
+}
+
+// brokenConn fails every read and write.
+type brokenConn struct {
+	net.Conn
+}
+
+func (c brokenConn) Read(p []byte) (int, error)  { return 0, errors.New("connection reset") }
+func (c brokenConn) Write(p []byte) (int, error) { return 0, errors.New("connection reset") }
+func (c brokenConn) Close() error                { return nil }
+
+func TestPortForwardServer_pipeErrors(t *testing.T) {
+	s := &PortForwardServer{Logger: log.New(ioutil.Discard, "", 0)}
+	stats := newForwardStats().Mapping("localhost:8080:localhost:8080")
+
+	// Both directions fail, but it's one failed connection
+	s.pipe(brokenConn{}, brokenConn{}, stats)
+	if got := atomic.LoadInt64(&stats.errors); got != 1 {
+		t.Fatalf("expect %d to eq 1", got)
+	}
+}
+
+func TestForwardStatsCommand_notServed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ui := new(cli.MockUi)
+	c := &ForwardStatsCommand{Meta: Meta{Ui: ui}}
+	if code := c.Run([]string{"-stats-addr", addr}); code != 1 {
+		t.Fatalf("expect %d to eq 1", code)
+	}
+
+	if !strings.Contains(ui.ErrorWriter.String(), "-stats-addr="+addr) {
+		t.Fatalf("expect hint to run with -stats-addr: %q", ui.ErrorWriter.String())
+	}
+}
+
+func TestForwardStatsCommand_cluster(t *testing.T) {
+	home, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	stats := newForwardStats()
+	stats.Mapping("localhost:8180:localhost:8180").connect()
+
+	server := httptest.NewServer(stats.Handler())
+	defer server.Close()
+
+	remove, err := writeForwardStatsAddr("dev", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other cluster doesn't record it
+	if addr, err := forwardStatsAddr("prod"); err != nil || addr != DefaultStatsAddr {
+		t.Fatalf("expect %q to eq %q: %v", addr, DefaultStatsAddr, err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &ForwardStatsCommand{Meta: Meta{Ui: ui}}
+	if code := c.Run([]string{"-name", "dev"}); code != 0 {
+		t.Fatalf("expect %d to eq 0: %s", code, ui.ErrorWriter.String())
+	}
+
+	if !strings.Contains(ui.OutputWriter.String(), "localhost:8180:localhost:8180") {
+		t.Fatalf("expect stats of dev cluster: %q", ui.OutputWriter.String())
+	}
+
+	remove()
+	if addr, err := forwardStatsAddr("dev"); err != nil || addr != DefaultStatsAddr {
+		t.Fatalf("expect %q to eq %q: %v", addr, DefaultStatsAddr, err)
+	}
+}
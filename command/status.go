@@ -0,0 +1,290 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/libcompose/docker"
+	"github.com/samalba/dockerclient"
+)
+
+// Exit codes of status command. Scripts can branch on them.
+const (
+	ExitCodeHealthy    = 0
+	ExitCodeError      = 1
+	ExitCodeNotRunning = 2
+	ExitCodeDegraded   = 3
+)
+
+// Cluster status which status command reports
+const (
+	StatusHealthy    = "healthy"
+	StatusDegraded   = "degraded"
+	StatusNotRunning = "not running"
+)
+
+// component is a cluster component which status command checks.
+type component struct {
+	Name string
+
+	// Service is compose service which runs this component.
+	Service string
+
+	// Port and Path is health check endpoint. If Port is 0,
+	// only container state is checked.
+	Port int
+	Path string
+}
+
+// clusterComponents returns components of the cluster whose components
+// listen on ports.
+func clusterComponents(ports ClusterPorts) []component {
+	return []component{
+		{Name: "etcd", Service: ServiceEtcd, Port: ports.EtcdPort, Path: "/health"},
+		{Name: "kubelet", Service: ServiceMaster, Port: ports.KubeletPort, Path: "/healthz"},
+		{Name: "proxy", Service: ServiceProxy},
+		{Name: "apiserver", Service: ServiceAPIServer, Port: ports.APIServerPort, Path: "/healthz"},
+		{Name: "controller-manager", Service: ServiceControllerManager, Port: ports.ControllerManagerPort, Path: "/healthz"},
+		{Name: "scheduler", Service: ServiceScheduler, Port: ports.SchedulerPort, Path: "/healthz"},
+	}
+}
+
+// ComponentStatus is status of a cluster component.
+type ComponentStatus struct {
+	Name      string `json:"name"`
+	Container string `json:"container,omitempty"`
+	Running   bool   `json:"running"`
+	Healthy   bool   `json:"healthy"`
+	Message   string `json:"message,omitempty"`
+}
+
+// ClusterStatus is status of the whole cluster.
+type ClusterStatus struct {
+	Status     string            `json:"status"`
+	Components []ComponentStatus `json:"components"`
+}
+
+type StatusCommand struct {
+	Meta
+}
+
+func (c *StatusCommand) Run(args []string) int {
+
+	var insecure bool
+	var format, logLevel string
+	var sshFlags SSHConfig
+	flags := c.Meta.flagSet("status")
+	flags.BoolVar(&insecure, "insecure", false, "")
+	flags.StringVar(&format, "format", "table", "")
+	flags.StringVar(&logLevel, "log-level", "info", "")
+	addSSHFlags(flags, &sshFlags)
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return ExitCodeError
+	}
+
+	if format != "table" && format != "json" {
+		c.Ui.Error(fmt.Sprintf("Invalid format %q: must be table or json", format))
+		return ExitCodeError
+	}
+
+	// Set up docker client
+	clientFactory, err := docker.NewDefaultClientFactory(
+		docker.ClientOpts{
+			TLS: !insecure,
+		},
+	)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to construct Docker client: %s", err))
+		return ExitCodeError
+	}
+
+	client := clientFactory.Create(nil)
+
+	// Components on docker VM only listen on localhost there, so
+	// health check endpoints are requested via SSH tunnel.
+	probe, closeProbe, err := newClusterProbe(c.Ui, newLogger(logLevel), sshFlags, ForwardAuto)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to connect to docker host: %s", err))
+		return ExitCodeError
+	}
+	defer closeProbe()
+
+	name := c.clusterName()
+	ports, err := loadClusterPorts(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
+		return ExitCodeError
+	}
+
+	status, err := checkClusterStatus(client, name, ports, probe)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to check cluster status: %s", err))
+		return ExitCodeError
+	}
+
+	switch format {
+	case "json":
+		buf, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to marshal status: %s", err))
+			return ExitCodeError
+		}
+		c.Ui.Output(string(buf))
+	default:
+		c.Ui.Output(formatStatusTable(status))
+	}
+
+	return statusExitCode(status)
+}
+
+func (c *StatusCommand) Synopsis() string {
+	return "Show health of each kubernetes component"
+}
+
+func (c *StatusCommand) Help() string {
+	helpText := `Show health of each kubernetes component (etcd, kubelet, proxy,
+API server, controller-manager and scheduler).
+
+Options:
+
+  -name=NAME        Name of the cluster. Default is "default".
+
+  -format=FORMAT    Output format, table or json. Default is table.
+
+  -insecure         Allow insecure non-TLS connection to docker client.
+
+  -ssh-host, -ssh-port, -ssh-user, -ssh-key, -insecure-skip-host-key
+                    SSH settings (See "forward -help"). If docker runs
+                    on VM, health is checked via SSH connection, since
+                    API server only listens on localhost there.
+
+  -log-level        Log level (DEBUG, INFO, WARN, ERROR).
+                    Default is INFO.
+
+Exit codes:
+
+  0    All components are healthy
+  1    Failed to check status (e.g., docker daemon is not working)
+  2    Cluster is not running
+  3    Cluster is degraded (some components are not healthy)
+`
+	return strings.TrimSpace(helpText)
+}
+
+// statusExitCode returns exit code of status command for the status.
+func statusExitCode(status *ClusterStatus) int {
+	switch status.Status {
+	case StatusHealthy:
+		return ExitCodeHealthy
+	case StatusNotRunning:
+		return ExitCodeNotRunning
+	default:
+		return ExitCodeDegraded
+	}
+}
+
+// checkClusterStatus checks status of each component of the named
+// cluster which listen on ports. Health check endpoints are requested
+// via probe.
+func checkClusterStatus(client dockerclient.Client, name string, ports ClusterPorts, probe *clusterProbe) (*ClusterStatus, error) {
+	components := clusterComponents(ports)
+	statuses := make([]ComponentStatus, 0, len(components))
+	running, healthy := 0, 0
+	for _, comp := range components {
+		status, err := checkComponentStatus(client, name, probe, comp)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.Running {
+			running++
+		}
+
+		if status.Healthy {
+			healthy++
+		}
+
+		statuses = append(statuses, *status)
+	}
+
+	cluster := &ClusterStatus{
+		Status:     StatusDegraded,
+		Components: statuses,
+	}
+
+	switch {
+	case running == 0:
+		cluster.Status = StatusNotRunning
+	case healthy == len(components):
+		cluster.Status = StatusHealthy
+	}
+
+	return cluster, nil
+}
+
+func checkComponentStatus(client dockerclient.Client, name string, probe *clusterProbe, comp component) (*ComponentStatus, error) {
+	containers, err := listServiceContainers(client, name, comp.Service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %s", err)
+	}
+
+	status := &ComponentStatus{
+		Name: comp.Name,
+	}
+
+	for _, container := range containers {
+		if isRunning(container) {
+			status.Running = true
+			status.Container = strings.TrimPrefix(container.Names[0], "/")
+			break
+		}
+	}
+
+	if !status.Running {
+		status.Message = "container is not running"
+		return status, nil
+	}
+
+	if comp.Port == 0 {
+		status.Healthy = true
+		return status, nil
+	}
+
+	if _, err := probe.Get(comp.Port, comp.Path); err != nil {
+		status.Message = err.Error()
+		return status, nil
+	}
+
+	status.Healthy = true
+	return status, nil
+}
+
+func formatStatusTable(status *ClusterStatus) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tCONTAINER\tSTATE\tHEALTH\tMESSAGE")
+	for _, s := range status.Components {
+		state, health := "stopped", "unhealthy"
+		if s.Running {
+			state = "running"
+		}
+		if s.Healthy {
+			health = "healthy"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Container, state, health, s.Message)
+	}
+	w.Flush()
+
+	fmt.Fprintf(&buf, "\nCluster is %s", status.Status)
+	return buf.String()
+}
@@ -0,0 +1,135 @@
+package command
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/samalba/dockerclient"
+)
+
+func TestStatusCommand_implement(t *testing.T) {
+	var _ cli.Command = &StatusCommand{}
+}
+
+// statusClient returns containers of each compose service.
+type statusClient struct {
+	dockerclient.Client
+	services map[string][]dockerclient.Container
+}
+
+func (c *statusClient) ListContainers(all, size bool, filters string) ([]dockerclient.Container, error) {
+	var filter map[string][]string
+	if err := json.Unmarshal([]byte(filters), &filter); err != nil {
+		return nil, err
+	}
+
+	for _, label := range filter["label"] {
+		if strings.HasPrefix(label, LabelService+"=") {
+			return c.services[strings.TrimPrefix(label, LabelService+"=")], nil
+		}
+	}
+	return c.services[""], nil
+}
+
+func runningContainer(name string) []dockerclient.Container {
+	return []dockerclient.Container{{Names: []string{"/" + name}, Status: "Up 3 minutes"}}
+}
+
+func TestCheckClusterStatus(t *testing.T) {
+	healthy := true
+	requested := make(map[string]bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested[r.Host] = true
+		if !healthy && r.URL.Path == "/healthz" {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	probe := testClusterProbe(ts)
+
+	client := &statusClient{services: map[string][]dockerclient.Container{
+		ServiceEtcd:              runningContainer("boot2k8sdev_etcd_1"),
+		ServiceAPIServer:         runningContainer("boot2k8sdev_apiserver_1"),
+		ServiceControllerManager: runningContainer("boot2k8sdev_controller-manager_1"),
+		ServiceScheduler:         runningContainer("boot2k8sdev_scheduler_1"),
+		ServiceMaster:            runningContainer("boot2k8sdev_master_1"),
+		ServiceProxy:             runningContainer("boot2k8sdev_proxy_1"),
+	}}
+
+	ports := clusterPorts(1)
+	status, err := checkClusterStatus(client, "dev", ports, probe)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if status.Status != StatusHealthy || len(status.Components) != len(clusterComponents(ports)) {
+		t.Fatalf("expect healthy cluster: %#v", status)
+	}
+
+	if got, want := status.Components[0].Container, "boot2k8sdev_etcd_1"; got != want {
+		t.Fatalf("expect %q to eq %q", got, want)
+	}
+
+	// Components are checked on ports of the cluster
+	for _, host := range []string{"localhost:4101", "localhost:8180", "localhost:10350"} {
+		if !requested[host] {
+			t.Fatalf("expect %s to be requested: %v", host, requested)
+		}
+	}
+
+	// kubelet and API server are not healthy
+	healthy = false
+	status, err = checkClusterStatus(client, "dev", ports, probe)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if status.Status != StatusDegraded {
+		t.Fatalf("expect %q to eq %q", status.Status, StatusDegraded)
+	}
+
+	if s := status.Components[1]; !s.Running || s.Healthy || s.Message == "" {
+		t.Fatalf("expect kubelet to be running but unhealthy: %#v", s)
+	}
+
+	// No container is running
+	client.services = nil
+	status, err = checkClusterStatus(client, "dev", ports, probe)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if status.Status != StatusNotRunning {
+		t.Fatalf("expect %q to eq %q", status.Status, StatusNotRunning)
+	}
+}
+
+func TestStatusExitCode(t *testing.T) {
+	cases := []struct {
+		status string
+		expect int
+	}{
+		{StatusHealthy, ExitCodeHealthy},
+		{StatusNotRunning, ExitCodeNotRunning},
+		{StatusDegraded, ExitCodeDegraded},
+	}
+
+	for i, tc := range cases {
+		if got := statusExitCode(&ClusterStatus{Status: tc.status}); got != tc.expect {
+			t.Fatalf("#%d expect %d to eq %d", i, got, tc.expect)
+		}
+	}
+}
+
+func TestStatusCommand_invalidFormat(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &StatusCommand{Meta: Meta{Ui: ui}}
+
+	if code := c.Run([]string{"-format", "yaml"}); code != ExitCodeError {
+		t.Fatalf("expect %d to eq %d", code, ExitCodeError)
+	}
+}
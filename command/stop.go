@@ -0,0 +1,158 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/docker/libcompose/docker"
+	"github.com/samalba/dockerclient"
+)
+
+// StopTimeout is seconds to wait for container to stop before killing it.
+const StopTimeout = 10
+
+type StopCommand struct {
+	Meta
+}
+
+func (c *StopCommand) Run(args []string) int {
+
+	var insecure bool
+	var configs stringSlice
+	flags := c.Meta.flagSet("stop")
+	flags.BoolVar(&insecure, "insecure", false, "")
+	flags.Var(&configs, "config", "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	name := c.clusterName()
+	compose, err := clusterCompose(name, configs)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read compose file: %s", err))
+		return 1
+	}
+
+	// Set up docker client
+	clientFactory, err := docker.NewDefaultClientFactory(
+		docker.ClientOpts{
+			TLS: !insecure,
+		},
+	)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to construct Docker client: %s", err))
+		return 1
+	}
+
+	state, err := LoadClusterState(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
+		return 1
+	}
+
+	// Setup new docker-compose project
+	project, err := newProject(name, compose, clientFactory)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to setup project: %s", err))
+		return 1
+	}
+
+	// Stop kubelet first, otherwise it restarts pod containers
+	// which are stopped below.
+	if err := project.Down(ServiceMaster); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to stop kubelet: %s", err))
+		return 1
+	}
+
+	client := clientFactory.Create(nil)
+
+	var relatedContainers []dockerclient.Container
+	if rootDir := podRootDir(c.Ui, name, state); rootDir != "" {
+		podContainers, err := listPodContainers(client, rootDir)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to list containers: %s", err))
+			return 1
+		}
+
+		for _, container := range podContainers {
+			if isRunning(container) {
+				relatedContainers = append(relatedContainers, container)
+			}
+		}
+	}
+
+	failed := false
+	for err := range stopContainers(client, relatedContainers, StopTimeout) {
+		c.Ui.Error(fmt.Sprintf("Error: %s", err))
+		failed = true
+	}
+
+	if err := project.Down(); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to stop project: %s", err))
+		return 1
+	}
+
+	if failed {
+		c.Ui.Error("Failed to stop some containers. Stop them with `docker stop`")
+		return 1
+	}
+
+	c.Ui.Info("Successfully stop kubernetes cluster")
+	c.Ui.Output("Containers and etcd data are kept. To start it again, use `start` command")
+	return 0
+}
+
+func (c *StopCommand) Synopsis() string {
+	return "Stop kubernetes cluster without deleting it"
+}
+
+func (c *StopCommand) Help() string {
+	helpText := `Stop kubernetes cluster. Containers (and etcd data) are kept,
+so the cluster can be started again by "start" command.
+
+Options:
+
+  -name=NAME      Name of the cluster. Default is "default".
+
+  -config=PATH    Compose file which is used for "up" (See "up -help").
+
+  -insecure       Allow insecure non-TLS connection to docker client.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// stopContainers stops all containers parallelly.
+// It returns error channel and if something wrong, error is sent there.
+func stopContainers(client dockerclient.Client, containers []dockerclient.Container, timeout int) chan error {
+
+	var wg sync.WaitGroup
+	errCh := make(chan error)
+	for _, container := range containers {
+		wg.Add(1)
+		go func(c dockerclient.Container) {
+			defer wg.Done()
+			if err := client.StopContainer(c.Id, timeout); err != nil {
+				errCh <- fmt.Errorf(
+					"failed to stop %s (%s): %s", c.Names[0], c.Id, err)
+			}
+		}(container)
+	}
+
+	go func() {
+		// Wait until all stop task and close error channel then
+		wg.Wait()
+		close(errCh)
+	}()
+
+	return errCh
+}
@@ -0,0 +1,11 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestStopCommand_implement(t *testing.T) {
+	var _ cli.Command = &StopCommand{}
+}
@@ -0,0 +1,236 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// KeepAliveInterval is how often keepalive request is sent to SSH server.
+	KeepAliveInterval = 15 * time.Second
+
+	// KeepAliveTimeout is time to wait reply of keepalive request. If no
+	// reply comes, transport is regarded as broken.
+	KeepAliveTimeout = 15 * time.Second
+
+	// DefaultReconnectInterval is initial interval to retry connecting
+	// SSH server. Interval is doubled while it's failing (up to MaxCheckInterval).
+	DefaultReconnectInterval = 1 * time.Second
+)
+
+// sshTunnel is SSH connection which is kept alive. It sends keepalive
+// requests periodically and when transport is broken (e.g., VM sleeps),
+// it reconnects to the server with backoff.
+type sshTunnel struct {
+	Logger *log.Logger
+
+	addr string
+	cfg  *ssh.ClientConfig
+
+	keepAliveInterval time.Duration
+	keepAliveTimeout  time.Duration
+	reconnectInterval time.Duration
+
+	mu     sync.Mutex
+	client *ssh.Client
+
+	doneCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// connectSSHTunnel establishes sshTunnel to SSH server of sshConfig.
+func connectSSHTunnel(logger *log.Logger, sshConfig *SSHConfig, auth []ssh.AuthMethod) (*sshTunnel, error) {
+	hostKeyCallback, err := sshConfig.HostKeyCallback(logger)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to setup host key verification: %s", err)
+	}
+
+	// Known host must present the known key
+	hostKeyAlgos, err := sshConfig.HostKeyAlgorithms()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to setup host key verification: %s", err)
+	}
+
+	cfg := &ssh.ClientConfig{
+		User:              sshConfig.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgos,
+		Timeout:           10 * time.Second,
+	}
+
+	sshServer := sshConfig.Addr()
+	tunnel := newSSHTunnel(logger, sshServer, cfg)
+	if err := tunnel.Connect(); err != nil {
+		return nil, fmt.Errorf(
+			"failed to establish connection with SSH server %s: %s", sshServer, err)
+	}
+	logger.Printf("[DEBUG] Establish connection with SSH server %s", sshServer)
+
+	return tunnel, nil
+}
+
+// newSSHTunnel returns sshTunnel to SSH server on addr.
+func newSSHTunnel(logger *log.Logger, addr string, cfg *ssh.ClientConfig) *sshTunnel {
+	return &sshTunnel{
+		Logger:            logger,
+		addr:              addr,
+		cfg:               cfg,
+		keepAliveInterval: KeepAliveInterval,
+		keepAliveTimeout:  KeepAliveTimeout,
+		reconnectInterval: DefaultReconnectInterval,
+		doneCh:            make(chan struct{}),
+	}
+}
+
+// Connect establishes connection with SSH server and starts to keep
+// it alive. It returns error if the first connection fails.
+func (t *sshTunnel) Connect() error {
+	client, err := ssh.Dial("tcp", t.addr, t.cfg)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.client = client
+	t.mu.Unlock()
+
+	go t.run(client)
+	return nil
+}
+
+// Dial dials addr via current SSH connection. While reconnecting,
+// it returns error.
+func (t *sshTunnel) Dial(network, addr string) (net.Conn, error) {
+	t.mu.Lock()
+	client := t.client
+	t.mu.Unlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("ssh connection to %s is lost (reconnecting)", t.addr)
+	}
+	return client.Dial(network, addr)
+}
+
+// Close closes SSH connection and stops reconnecting. It can be called
+// more than once (e.g., on error and on shutdown).
+func (t *sshTunnel) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.doneCh)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.client != nil {
+			err = t.client.Close()
+		}
+	})
+	return err
+}
+
+// run keeps the connection alive until Close is called.
+func (t *sshTunnel) run(client *ssh.Client) {
+	for {
+		err := t.keepAlive(client)
+
+		select {
+		case <-t.doneCh:
+			return
+		default:
+		}
+
+		t.mu.Lock()
+		t.client = nil
+		t.mu.Unlock()
+		t.Logger.Printf("[INFO] SSH connection to %s is lost: %s", t.addr, err)
+
+		client = t.reconnect()
+		if client == nil {
+			return
+		}
+	}
+}
+
+// keepAlive sends keepalive requests until transport is broken.
+// It returns the reason why transport is regarded as broken.
+func (t *sshTunnel) keepAlive(client *ssh.Client) error {
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- client.Wait()
+	}()
+
+	ticker := time.NewTicker(t.keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-waitCh:
+			if err == nil {
+				err = fmt.Errorf("connection is closed")
+			}
+			return err
+		case <-ticker.C:
+			replyCh := make(chan error, 1)
+			go func() {
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				replyCh <- err
+			}()
+
+			var err error
+			select {
+			case err = <-replyCh:
+			case <-time.After(t.keepAliveTimeout):
+				err = fmt.Errorf("no reply to keepalive in %s", t.keepAliveTimeout)
+			}
+
+			if err != nil {
+				// Closing makes sure that Dial on the broken client fails
+				client.Close()
+				<-waitCh
+				return err
+			}
+			t.Logger.Printf("[DEBUG] Keepalive to %s", t.addr)
+		}
+	}
+}
+
+// reconnect connects to SSH server with backoff until it succeeds.
+// It returns nil if Close is called while reconnecting.
+func (t *sshTunnel) reconnect() *ssh.Client {
+	interval := t.reconnectInterval
+	for {
+		select {
+		case <-t.doneCh:
+			return nil
+		case <-time.After(interval):
+		}
+
+		client, err := ssh.Dial("tcp", t.addr, t.cfg)
+		if err != nil {
+			t.Logger.Printf("[INFO] Failed to reconnect to SSH server %s: %s (retry in %s)", t.addr, err, nextInterval(interval))
+			interval = nextInterval(interval)
+			continue
+		}
+
+		t.mu.Lock()
+		select {
+		case <-t.doneCh:
+			t.mu.Unlock()
+			client.Close()
+			return nil
+		default:
+		}
+		t.client = client
+		t.mu.Unlock()
+
+		t.Logger.Printf("[INFO] Reconnected to SSH server %s", t.addr)
+		return client
+	}
+}
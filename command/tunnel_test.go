@@ -0,0 +1,135 @@
+package command
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startSSHServer starts SSH server which accepts any client and rejects
+// all channels. Accepted connections are sent to the returned channel.
+func startSSHServer(t *testing.T) (net.Listener, chan net.Conn) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &ssh.ServerConfig{NoClientAuth: true}
+	cfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connCh := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+				if err != nil {
+					return
+				}
+				connCh <- conn
+
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					ch.Reject(ssh.Prohibited, "not supported")
+				}
+			}()
+		}
+	}()
+
+	return l, connCh
+}
+
+func TestSSHTunnel_reconnect(t *testing.T) {
+	l, connCh := startSSHServer(t)
+	defer l.Close()
+
+	tunnel := &sshTunnel{
+		Logger: log.New(ioutil.Discard, "", 0),
+		addr:   l.Addr().String(),
+		cfg: &ssh.ClientConfig{
+			User:            "docker",
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		},
+		keepAliveInterval: 10 * time.Millisecond,
+		keepAliveTimeout:  time.Second,
+		reconnectInterval: 10 * time.Millisecond,
+		doneCh:            make(chan struct{}),
+	}
+
+	if err := tunnel.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Close()
+
+	// Break transport from server side
+	select {
+	case conn := <-connCh:
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout: no connection")
+	}
+
+	select {
+	case <-connCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout: not reconnected")
+	}
+
+	// Dial must reach the new connection (server rejects channel)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := tunnel.Dial("tcp", "localhost:8080")
+		if err != nil && strings.Contains(err.Error(), "not supported") {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expect channel to be rejected by new connection: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSSHTunnel_Close(t *testing.T) {
+	l, _ := startSSHServer(t)
+	defer l.Close()
+
+	tunnel := newSSHTunnel(log.New(ioutil.Discard, "", 0), l.Addr().String(), &ssh.ClientConfig{
+		User:            "docker",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+
+	if err := tunnel.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tunnel.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Second call must not panic (e.g., closing closed channel)
+	if err := tunnel.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -1,31 +1,28 @@
 package command
 
 import (
-	"bufio"
-	"encoding/json"
-	"flag"
 	"fmt"
-	"io"
-	"log"
 	"os"
 	"os/signal"
-	"runtime"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/docker/libcompose/docker"
-	"github.com/docker/libcompose/project"
-	"github.com/hashicorp/logutils"
+	"github.com/mitchellh/cli"
 	"github.com/samalba/dockerclient"
-	"github.com/tcnksm/boot2kubernetes/config"
 )
 
 const (
-	// CheckInterval is how often check k8s container is ready
-	CheckInterval = 3 * time.Second
+	// DefaultCheckInterval is how often check k8s cluster is ready.
+	// Interval is doubled while check is failing (up to MaxCheckInterval).
+	DefaultCheckInterval = 3 * time.Second
 
-	// CheckTimeout is timeout for waiting k8s container is ready
-	CheckTimeOut = 300 * time.Second
+	// MaxCheckInterval is upper limit of check interval
+	MaxCheckInterval = 30 * time.Second
+
+	// DefaultCheckTimeOut is timeout for waiting k8s cluster is ready
+	DefaultCheckTimeOut = 300 * time.Second
 )
 
 type UpCommand struct {
@@ -34,30 +31,88 @@ type UpCommand struct {
 
 func (c *UpCommand) Run(args []string) int {
 	var insecure bool
-	var logLevel string
-	flags := flag.NewFlagSet("up", flag.ContinueOnError)
+	var logLevel, k8sVersion, dataDir string
+	var timeout, pollInterval time.Duration
+	var configs stringSlice
+	var mappings portMappings
+	var sshFlags SSHConfig
+	var watchServices, detach bool
+	var forwardMode, socksAddr, statsAddr string
+	flags := c.Meta.flagSet("up")
 	flags.BoolVar(&insecure, "insecure", false, "")
+	flags.Var(&configs, "config", "")
+	flags.Var(&mappings, "L", "")
+	flags.BoolVar(&watchServices, "watch-services", true, "")
+	flags.BoolVar(&detach, "detach", false, "")
+	flags.StringVar(&forwardMode, "forward", ForwardAuto, "")
+	flags.StringVar(&socksAddr, "socks", "", "")
+	flags.StringVar(&statsAddr, "stats-addr", "", "")
+	addSSHFlags(flags, &sshFlags)
 	flags.StringVar(&logLevel, "log-level", "info", "")
+	flags.StringVar(&k8sVersion, "k8s-version", "", "")
+	flags.StringVar(&dataDir, "data-dir", "", "")
+	flags.DurationVar(&timeout, "timeout", DefaultCheckTimeOut, "")
+	flags.DurationVar(&pollInterval, "poll-interval", DefaultCheckInterval, "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 
-	errR, errW := io.Pipe()
-	errScanner := bufio.NewScanner(errR)
-	go func() {
-		for errScanner.Scan() {
-			c.Ui.Error(errScanner.Text())
-		}
-	}()
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
 
-	flags.SetOutput(errW)
+	if timeout <= 0 || pollInterval <= 0 {
+		c.Ui.Error("-timeout and -poll-interval must be positive duration")
+		return 1
+	}
 
-	if err := flags.Parse(args); err != nil {
+	if err := validateForwardMode(forwardMode); err != nil {
+		c.Ui.Error(err.Error())
 		return 1
 	}
 
-	compose, err := config.Asset("k8s.yml")
+	// Create logger with Log level
+	logger := newLogger(logLevel)
+
+	name := c.clusterName()
+
+	// Each cluster has its own ports, so it can run with other clusters
+	offset, err := allocatePortOffset(name)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to allocate ports: %s", err))
+		return 1
+	}
+
+	params, err := newComposeParams(name, k8sVersion, offset)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Invalid kubernetes version: %s", err))
+		return 1
+	}
+
+	if dataDir != "" {
+		// Volume host path must be absolute
+		params.DataDir, err = filepath.Abs(dataDir)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Invalid data directory: %s", err))
+			return 1
+		}
+	}
+
+	// Compose files are recorded in cluster state, so make them absolute
+	paths := configPaths(configs)
+	for i, path := range paths {
+		if paths[i], err = filepath.Abs(path); err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Invalid compose file path: %s", err))
+			return 1
+		}
+	}
+
+	compose, err := loadCompose(paths, params)
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
-			"Failed to read k8s.yml: %s", err))
+			"Failed to read compose file: %s", err))
 		return 1
 	}
 
@@ -73,25 +128,46 @@ func (c *UpCommand) Run(args []string) int {
 		return 1
 	}
 
-	// Setup new docker-compose project
-	context := &docker.Context{
-		Context: project.Context{
-			Log:          false,
-			ComposeBytes: compose,
-			ProjectName:  "boot2k8s",
-		},
-		ClientFactory: clientFactory,
+	client := clientFactory.Create(nil)
+
+	// Kubernetes components use host network, so clusters which
+	// have the same ports can't run at the same time.
+	if !checkPortConflict(c.Ui, client, name, offset) {
+		return 1
 	}
 
+	// Components on docker VM only listen on localhost there, so
+	// readiness is checked via SSH tunnel.
+	probe, closeProbe, err := newClusterProbe(c.Ui, logger, sshFlags, forwardMode)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to connect to docker host: %s", err))
+		return 1
+	}
+	defer closeProbe()
+
 	// Setup new docker-compose project
-	project, err := docker.NewProject(context)
+	project, err := newProject(name, compose, clientFactory)
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Failed to setup project: %s", err))
 		return 1
 	}
 
-	c.Ui.Output("Start kubernetes cluster!")
+	state := &ClusterState{
+		Name:           name,
+		Project:        projectName(name),
+		K8sVersion:     params.Version,
+		Configs:        paths,
+		DataDir:        params.DataDir,
+		DockerHost:     os.Getenv("DOCKER_HOST"),
+		APIServer:      kubectlServer(params.ClusterPorts),
+		PortOffset:     offset,
+		KubeletRootDir: params.KubeletRootDir,
+		CreatedAt:      time.Now(),
+	}
+
+	c.Ui.Output(fmt.Sprintf("Start kubernetes cluster %q (v%s)!", name, params.Version))
 	upErrCh := make(chan error)
 	go func() {
 		if err := project.Up(); err != nil {
@@ -99,13 +175,16 @@ func (c *UpCommand) Run(args []string) int {
 		}
 	}()
 
-	client := clientFactory.Create(nil)
-
 	sigCh := make(chan os.Signal)
 	signal.Notify(sigCh, os.Interrupt)
 
+	// Stop checking cluster when returning (e.g., timeout)
+	stopCheckCh := make(chan struct{})
+	defer close(stopCheckCh)
+
+	checks := clusterReadinessChecks(client, name, params.ClusterPorts, probe)
 	select {
-	case <-afterContainerReady(client):
+	case <-afterClusterReady(c.Ui, logger, checks, pollInterval, stopCheckCh):
 		c.Ui.Info("Successfully start kubernetes cluster")
 	case err := <-upErrCh:
 		c.Ui.Error("")
@@ -117,39 +196,96 @@ func (c *UpCommand) Run(args []string) int {
 		c.Ui.Error("Interrupted!")
 		c.Ui.Error("It's ambiguous that boot2kubernetes could correctly start containers.")
 		c.Ui.Error("So request to kubelet may be failed. Check the containers are working")
-		c.Ui.Error("with `docker ps` command by yourself.")
+		c.Ui.Error("with `boot2k8s status` and `boot2k8s logs` command.")
 		return 1
-	case <-time.After(CheckTimeOut):
+	case <-time.After(timeout):
 		c.Ui.Error("")
-		c.Ui.Error("Timeout happened while waiting cluster containers are ready.")
+		c.Ui.Error("Timeout happened while waiting cluster is ready.")
 		c.Ui.Error("It's ambiguous that boot2kubernetes could correctly start containers.")
 		c.Ui.Error("So request to kubelet may be failed. Check the containers are working")
-		c.Ui.Error("with `docker ps` command by yourself.")
+		c.Ui.Error("with `boot2k8s status` and `boot2k8s logs` command.")
+		return 1
+	}
+
+	// If docker runs on VM (e.g., boot2docker), port forwarding is needed
+	// unless it's already running (kubectl connects to localhost anyway).
+	forward, err := needForward(forwardMode, params.ClusterPorts)
+	if err != nil {
+		c.Ui.Error(err.Error())
 		return 1
 	}
 
-	// If docker runs on boot2docker, port forwarding is needed.
-	if runtime.GOOS == "darwin" {
+	// State is saved only when the cluster is ready, so it's never
+	// left for the cluster which failed to start.
+	state.Forwarded = forward
+	if err := state.Save(); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to save cluster state: %s", err))
+		return 1
+	}
+
+	// Make kubectl connect to the cluster without configuration
+	kubeconfig, err := writeKubeconfig(name, state.APIServer)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to write kubeconfig: %s", err))
+		return 1
+	}
+	c.Ui.Output(fmt.Sprintf(
+		"kubectl context %q is written to %s", kubeconfigName(name), kubeconfig))
+
+	if forward {
 
 		c.Ui.Output("")
-		c.Ui.Output("==> WARNING: You're running docker on boot2docker!")
+		c.Ui.Output("==> WARNING: You're running docker on VM (e.g., boot2docker)!")
 		c.Ui.Output("  To connect to master api server from local environment,")
 		c.Ui.Output("  port forwarding is needed. boot2kubernetes starts ")
+
+		if detach {
+			c.Ui.Output("  server for that in background.\n")
+			pid, err := startForwardDaemon(name,
+				forwardArgs(c.Meta.Name, logLevel, mappings, sshFlags, watchServices, socksAddr, statsAddr))
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Failed to start port forwarding server: %s", err))
+				return 1
+			}
+
+			outputForwardDaemon(c.Ui, name, pid)
+			return 0
+		}
+
 		c.Ui.Output("  server for that. To stop server, use ^C (Interrupt).\n")
 
-		// Create logger with Log level
-		logger := log.New(&logutils.LevelFilter{
-			Levels:   []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERROR"},
-			MinLevel: (logutils.LogLevel)(strings.ToUpper(logLevel)),
-			Writer:   os.Stderr,
-		}, "", log.LstdFlags)
-		logger.Printf("[DEBUG] LogLevel: %s", logLevel)
+		// Reuse SSH settings of readiness checks (passphrase is asked once)
+		sshConfig, auth := probe.SSH, probe.Auth
+		if sshConfig == nil {
+			sshConfig, err = resolveSSHConfig(sshFlags)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Failed to read SSH settings: %s", err))
+				return 1
+			}
+
+			auth, err = sshConfig.AuthMethods(c.Ui)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Failed to construct SSH auth method: %s", err))
+				return 1
+			}
+			defer sshConfig.Close()
+		}
 
 		// Setup port forward server
 		server := &PortForwardServer{
-			Logger:       logger,
-			LocalServer:  DefaultLocalServer,
-			RemoteServer: DefaultRemoteServer,
+			Logger:        logger,
+			Mappings:      mappings.withDefault(params.ClusterPorts),
+			APIServer:     params.RemoteServer(),
+			SSH:           sshConfig,
+			Auth:          auth,
+			WatchServices: watchServices,
+			SocksAddr:     socksAddr,
+			StatsAddr:     statsAddr,
 		}
 
 		doneCh, errCh, err := server.Start()
@@ -159,6 +295,18 @@ func (c *UpCommand) Run(args []string) int {
 			return 1
 		}
 
+		// "forward stats -name" finds statistics of this cluster's server
+		if statsAddr != "" {
+			remove, err := writeForwardStatsAddr(name, statsAddr)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"Failed to record statistics address: %s", err))
+				close(doneCh)
+				return 1
+			}
+			defer remove()
+		}
+
 		sigCh := make(chan os.Signal)
 		signal.Notify(sigCh, os.Interrupt)
 		select {
@@ -178,6 +326,34 @@ func (c *UpCommand) Run(args []string) int {
 	return 0
 }
 
+// checkPortConflict checks no other cluster which has the same ports as
+// the named cluster of offset is running. If it's running (or checking
+// fails), it's reported via ui and false is returned.
+func checkPortConflict(ui cli.Ui, client dockerclient.Client, name string, offset int) bool {
+	clusters, err := listClusterContainers(client)
+	if err != nil {
+		ui.Error(fmt.Sprintf(
+			"Failed to list containers: %s", err))
+		return false
+	}
+
+	other, err := conflictingCluster(clusters, name, offset)
+	if err != nil {
+		ui.Error(fmt.Sprintf(
+			"Failed to read cluster state: %s", err))
+		return false
+	}
+
+	if other != "" {
+		ui.Error(fmt.Sprintf(
+			"Cluster %q is already running on the same ports on this docker daemon.", other))
+		ui.Error(fmt.Sprintf(
+			"Stop it first with `stop -name=%s`", other))
+		return false
+	}
+	return true
+}
+
 func (c *UpCommand) Synopsis() string {
 	return "Up kubernetes cluster"
 }
@@ -187,44 +363,75 @@ func (c *UpCommand) Help() string {
 
 Options:
 
-  -insecure    Allow insecure non-TLS connection to docker client. 
+  -name=NAME      Name of the cluster. It's used for docker labels and
+                  state of the cluster. It can be also set via
+                  BOOT2K8S_NAME. Default is "default". Kubernetes
+                  components use host network, so each cluster has
+                  its own ports to run at the same time with others.
+                  The default cluster uses the default ports (e.g.,
+                  API server on 8080), and each named cluster uses
+                  them shifted by multiple of 100 (e.g., 8180).
+
+  -config=PATH    Compose file to use instead of embedded k8s.yml.
+                  Can be specified multiple times, files are merged
+                  in order. It can be also set via BOOT2K8S_CONFIG
+                  (multiple files are separated by ':'). Files named
+                  *.tmpl are rendered as go template (e.g., {{.Hyperkube}}),
+                  others are used as they are.
+
+  -data-dir=PATH  Directory on docker host to store etcd data. Data
+                  in this directory is kept after destroy, so cluster
+                  can be recreated with the same objects. On boot2docker,
+                  it must be under the shared directory (e.g., /Users).
+
+  -insecure       Allow insecure non-TLS connection to docker client.
+
+  -L=MAPPING      Port mapping for port forwarding on boot2docker (See
+                  "forward -help"). Can be specified multiple times.
+                  API server of the cluster (e.g., 8080:8080) is always
+                  forwarded in addition.
+
+  -forward=MODE   Whether to run port forwarding, auto, always or never.
+                  With auto, it runs only when docker daemon is not on
+                  this host (DOCKER_HOST) and API server is not reachable
+                  on localhost. Default is auto. Unless it's never,
+                  readiness of the cluster on docker VM is checked via
+                  SSH connection, since API server only listens on
+                  localhost there. For the same reason, kubectl always
+                  connects to API server on localhost if docker runs
+                  on VM (run port forwarding by yourself with never).
+
+  -detach         Run port forwarding server on boot2docker in background
+                  instead of foreground (See "forward -help").
+
+  -watch-services Forward NodePorts of Services automatically on
+                  boot2docker (See "forward -help"). Default is true.
+
+  -socks=ADDR     Run SOCKS5 proxy server on ADDR with port forwarding
+                  on boot2docker (See "forward -help").
+
+  -stats-addr=ADDR
+                  Serve statistics of port forwarding on ADDR
+                  (See "forward -help").
+
+  -ssh-host, -ssh-port, -ssh-user, -ssh-key, -insecure-skip-host-key
+                  SSH settings for port forwarding and readiness checks
+                  (See "forward -help").
+
+  -timeout=DUR    Timeout for waiting cluster is ready (e.g., 10m).
+                  Default is 5m.
+
+  -poll-interval=DUR
+                  Initial interval to check cluster is ready. It's
+                  doubled while cluster is not ready (up to 30s, or
+                  the initial interval if it's longer). Default is 3s.
+
+  -k8s-version    Kubernetes version to start. Available versions are
+                  listed by "versions" command. By default, the default
+                  version of the catalog is used.
+
+  -log-level      Log level (DEBUG, INFO, WARN, ERROR).
+                  Default is INFO.
 `
 	return strings.TrimSpace(helpText)
 }
-
-// afterContainerReady waits for the cluster ready and then sends the struct{}
-// on the returned channel. Detection of cluster ready is very heuristic way,
-// just checking number of container which is needed for running cluster.
-func afterContainerReady(c dockerclient.Client) chan struct{} {
-	doneCh := make(chan struct{})
-
-	// Marshaling to post filter as API request
-	filterLocalMasterStr, err := json.Marshal(FilterLocalMaster)
-	if err != nil {
-		// Should not reach here....
-		panic(fmt.Sprintf(
-			"Failed to marshal FilterLocalMaster: %s", err))
-	}
-
-	ticker := time.NewTicker(CheckInterval)
-	go func() {
-		fmt.Fprintf(os.Stderr, "Wait until containers are ready")
-		for _ = range ticker.C {
-			fmt.Fprintf(os.Stderr, ".")
-			// Get Container info from daemon based on filter
-			localMasters, err := c.ListContainers(true, false, (string)(filterLocalMasterStr))
-			if err != nil {
-				// Just ignore error
-				continue
-			}
-
-			if len(localMasters) > 3 {
-				fmt.Fprintf(os.Stderr, "\n")
-				doneCh <- struct{}{}
-				ticker.Stop()
-			}
-		}
-	}()
-
-	return doneCh
-}
@@ -0,0 +1,427 @@
+package command
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kardianos/osext"
+	"github.com/tcnksm/go-latest"
+)
+
+const (
+	// EnvReleaseURL is environmental variable to change release source.
+	EnvReleaseURL = "BOOT2K8S_RELEASE_URL"
+
+	// DefaultReleaseURL is where release artifacts are uploaded.
+	// Artifacts of each version are under /{version}/ (See scripts/release.sh).
+	DefaultReleaseURL = "https://github.com/tcnksm/boot2kubernetes/releases/download"
+
+	// LatestFileName is file on release source other than GitHub which
+	// has the latest version (e.g., "0.2.0").
+	LatestFileName = "latest"
+
+	// BinaryName is name of the binary in release archive.
+	BinaryName = "boot2k8s"
+)
+
+type UpgradeCommand struct {
+	Meta
+
+	Name    string
+	Version string
+
+	// executable returns path of the binary to replace.
+	// If nil, the running executable is replaced.
+	executable func() (string, error)
+}
+
+func (c *UpgradeCommand) Run(args []string) int {
+
+	var source, version string
+	var force bool
+	flags := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	flags.StringVar(&source, "source", "", "")
+	flags.StringVar(&version, "version", "", "")
+	flags.BoolVar(&force, "force", false, "")
+	flags.Usage = func() { c.Ui.Error(c.Help()) }
+
+	errR, errW := io.Pipe()
+	errScanner := bufio.NewScanner(errR)
+	go func() {
+		for errScanner.Scan() {
+			c.Ui.Error(errScanner.Text())
+		}
+	}()
+
+	flags.SetOutput(errW)
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if source == "" {
+		source = os.Getenv(EnvReleaseURL)
+	}
+	if source == "" {
+		source = DefaultReleaseURL
+	}
+	source = strings.TrimRight(source, "/")
+
+	if version == "" {
+		latestVersion, err := c.checkLatest(source)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to check latest version: %s", err))
+			return 1
+		}
+
+		cmp, err := compareVersions(c.Version, latestVersion)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to compare versions: %s", err))
+			return 1
+		}
+
+		if cmp == 0 && !force {
+			c.Ui.Info(fmt.Sprintf("%s %s is already the latest version", c.Name, c.Version))
+			return 0
+		}
+		version = latestVersion
+	}
+	version = strings.TrimPrefix(version, "v")
+
+	// Never replace newer binary (e.g., local build) by accident
+	if !force {
+		cmp, err := compareVersions(c.Version, version)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf(
+				"Failed to compare versions: %s", err))
+			return 1
+		}
+
+		if cmp > 0 {
+			c.Ui.Error(fmt.Sprintf(
+				"%s %s is newer than %s. To downgrade, use -force", c.Name, c.Version, version))
+			return 1
+		}
+	}
+
+	executable := c.executable
+	if executable == nil {
+		executable = osext.Executable
+	}
+
+	execPath, err := executable()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to detect executable path: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Upgrade %s %s to %s", c.Name, c.Version, version))
+
+	archiveName := releaseArchiveName(version, runtime.GOOS, runtime.GOARCH)
+	archive, err := httpDownload(fmt.Sprintf("%s/%s/%s", source, version, archiveName))
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to download %s: %s", archiveName, err))
+		return 1
+	}
+
+	shasums, err := httpDownload(fmt.Sprintf("%s/%s/%s_SHASUMS", source, version, version))
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to download checksum file: %s", err))
+		return 1
+	}
+
+	if err := verifyChecksum(archive, archiveName, shasums); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to verify %s: %s", archiveName, err))
+		return 1
+	}
+
+	binary, err := extractBinary(archive, binaryName(runtime.GOOS))
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to extract binary from %s: %s", archiveName, err))
+		return 1
+	}
+
+	if err := replaceExecutable(execPath, binary); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to replace %s: %s", execPath, err))
+		return 1
+	}
+
+	c.Ui.Info(fmt.Sprintf("Successfully upgrade %s to %s", execPath, version))
+	return 0
+}
+
+func (c *UpgradeCommand) Synopsis() string {
+	return fmt.Sprintf("Upgrade %s to the latest version", c.Name)
+}
+
+func (c *UpgradeCommand) Help() string {
+	helpText := `Upgrade boot2k8s binary to the latest version. The binary for
+the current OS/Arch is downloaded and verified with checksum file
+before replacing the running executable.
+
+Options:
+
+  -version=VERSION    Version to upgrade to. By default, the latest
+                      version on the release source is used.
+
+  -source=URL         Base URL of release artifacts. It can be also set
+                      via BOOT2K8S_RELEASE_URL. Artifacts are downloaded
+                      from URL/VERSION/ and the latest version is read
+                      from URL/latest. Default is GitHub release page.
+
+  -force              Download binary even if it's the latest version,
+                      or older than the running one (downgrade).
+`
+	return strings.TrimSpace(helpText)
+}
+
+// checkLatest returns the latest version on release source. Versions on
+// GitHub are checked via its tags, and other sources must have
+// LatestFileName.
+func (c *UpgradeCommand) checkLatest(source string) (string, error) {
+	if source == DefaultReleaseURL {
+		githubTag := &latest.GithubTag{
+			Owner:      "tcnksm",
+			Repository: "boot2kubernetes",
+		}
+
+		res, err := latest.Check(githubTag, c.Version)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimPrefix(res.Current, "v"), nil
+	}
+
+	body, err := httpDownload(fmt.Sprintf("%s/%s", source, LatestFileName))
+	if err != nil {
+		return "", err
+	}
+
+	version := strings.TrimPrefix(strings.TrimSpace(string(body)), "v")
+	if version == "" {
+		return "", fmt.Errorf("%s is empty", LatestFileName)
+	}
+
+	return version, nil
+}
+
+// compareVersions compares semantic versions a and b (e.g., 0.1.0 and
+// v0.2.0). It returns -1 if a is older, 1 if a is newer and 0 if they
+// are the same. Pre-release (e.g., 0.2.0-dev) is older than its release.
+func compareVersions(a, b string) (int, error) {
+	parse := func(v string) ([]int, string, error) {
+		v = strings.TrimPrefix(v, "v")
+		pre := ""
+		if i := strings.IndexAny(v, "-+"); i >= 0 {
+			v, pre = v[:i], v[i:]
+		}
+
+		parts := strings.Split(v, ".")
+		nums := make([]int, 3)
+		if len(parts) > len(nums) {
+			return nil, "", fmt.Errorf("invalid version %q", v)
+		}
+
+		for i, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return nil, "", fmt.Errorf("invalid version %q", v)
+			}
+			nums[i] = n
+		}
+
+		// Build metadata doesn't affect precedence
+		if strings.HasPrefix(pre, "+") {
+			pre = ""
+		}
+		return nums, pre, nil
+	}
+
+	an, apre, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+
+	bn, bpre, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range an {
+		switch {
+		case an[i] < bn[i]:
+			return -1, nil
+		case an[i] > bn[i]:
+			return 1, nil
+		}
+	}
+
+	switch {
+	case apre == bpre:
+		return 0, nil
+	case apre == "":
+		return 1, nil
+	case bpre == "":
+		return -1, nil
+	case apre < bpre:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+// binaryName returns name of the binary in release archive for goos.
+func binaryName(goos string) string {
+	if goos == "windows" {
+		return BinaryName + ".exe"
+	}
+	return BinaryName
+}
+
+// releaseArchiveName returns name of release archive for the platform.
+// It must be same as what scripts/release.sh generates.
+func releaseArchiveName(version, goos, goarch string) string {
+	return fmt.Sprintf("boot2kubernetes_%s_%s_%s.zip", version, goos, goarch)
+}
+
+// httpDownload downloads the given URL and returns its body.
+func httpDownload(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returns %s", url, res.Status)
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
+
+// verifyChecksum verifies data with checksum of name in shasums. shasums
+// is output of `shasum` command ("<checksum>  <name>" per line). Both
+// SHA1 and SHA256 are supported (detected by length of checksum).
+func verifyChecksum(data []byte, name string, shasums []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(shasums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+
+		expect := strings.ToLower(fields[0])
+		var h hash.Hash
+		switch len(expect) {
+		case sha1.Size * 2:
+			h = sha1.New()
+		case sha256.Size * 2:
+			h = sha256.New()
+		default:
+			return fmt.Errorf("unknown checksum format: %s", expect)
+		}
+
+		h.Write(data)
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != expect {
+			return fmt.Errorf("checksum mismatch (expect %s, actual %s)", expect, actual)
+		}
+		return nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("checksum of %s is not found", name)
+}
+
+// extractBinary returns the file which is named name from zip archive.
+func extractBinary(archive []byte, name string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range r.File {
+		if filepath.Base(f.Name) != name {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		return ioutil.ReadAll(rc)
+	}
+
+	return nil, fmt.Errorf("%s is not found in archive", name)
+}
+
+// replaceExecutable replaces the executable on path with binary atomically.
+// New binary is written to temporary file in the same directory and then
+// renamed, so the executable is never partially written.
+func replaceExecutable(path string, binary []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+
+	// Running executable can not be overwritten on windows,
+	// but it can be renamed.
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
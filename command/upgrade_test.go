@@ -0,0 +1,205 @@
+package command
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestUpgradeCommand_implement(t *testing.T) {
+	var _ cli.Command = &UpgradeCommand{}
+}
+
+func TestUpgrade_localRelease(t *testing.T) {
+	// Create release archive which includes new binary
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, _ := zw.Create("boot2k8s")
+	w.Write([]byte("new binary"))
+	zw.Close()
+
+	archiveName := releaseArchiveName("0.2.0", "linux", "amd64")
+	sum := sha1.Sum(archive.Bytes())
+	shasums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), archiveName)
+
+	// Local stand-in of release page
+	mux := http.NewServeMux()
+	mux.HandleFunc("/0.2.0/"+archiveName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive.Bytes())
+	})
+	mux.HandleFunc("/0.2.0/0.2.0_SHASUMS", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, shasums)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	data, err := httpDownload(ts.URL + "/0.2.0/" + archiveName)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sums, err := httpDownload(ts.URL + "/0.2.0/0.2.0_SHASUMS")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := verifyChecksum(data, archiveName, sums); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := verifyChecksum(append(data, 0), archiveName, sums); err == nil {
+		t.Fatalf("expect checksum mismatch error")
+	}
+
+	binary, err := extractBinary(data, "boot2k8s")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	execPath := filepath.Join(dir, "boot2k8s")
+	if err := ioutil.WriteFile(execPath, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceExecutable(execPath, binary); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	got, _ := ioutil.ReadFile(execPath)
+	if string(got) != "new binary" {
+		t.Fatalf("expect executable to be replaced: %q", got)
+	}
+
+	info, _ := os.Stat(execPath)
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("expect mode to be kept: %s", info.Mode())
+	}
+}
+
+// testReleaseServer returns local stand-in of release source whose
+// latest version is version.
+func testReleaseServer(t *testing.T, version string) *httptest.Server {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create(binaryName(runtime.GOOS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("new binary"))
+	zw.Close()
+
+	archiveName := releaseArchiveName(version, runtime.GOOS, runtime.GOARCH)
+	sum := sha1.Sum(archive.Bytes())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+LatestFileName, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, version)
+	})
+	mux.HandleFunc("/"+version+"/"+archiveName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive.Bytes())
+	})
+	mux.HandleFunc("/"+version+"/"+version+"_SHASUMS", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  %s\n", hex.EncodeToString(sum[:]), archiveName)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestUpgradeCommand_Run(t *testing.T) {
+	ts := testReleaseServer(t, "0.2.0")
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "boot2k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Name of executable can be different from the one in archive
+	execPath := filepath.Join(dir, "b2k")
+
+	cases := []struct {
+		version string
+		args    []string
+		code    int
+		expect  string
+	}{
+		// Already the latest
+		{"0.2.0", []string{"-source", ts.URL}, 0, "old binary"},
+		{"0.1.0", []string{"-source", ts.URL}, 0, "new binary"},
+
+		// Newer than the latest release (e.g., local build)
+		{"0.3.0", []string{"-source", ts.URL}, 1, "old binary"},
+		{"0.3.0", []string{"-source", ts.URL, "-force"}, 0, "new binary"},
+		{"0.2.0-dev", []string{"-source", ts.URL}, 0, "new binary"},
+	}
+
+	for i, tc := range cases {
+		if err := ioutil.WriteFile(execPath, []byte("old binary"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		ui := new(cli.MockUi)
+		c := &UpgradeCommand{
+			Meta:       Meta{Ui: ui},
+			Name:       "boot2k8s",
+			Version:    tc.version,
+			executable: func() (string, error) { return execPath, nil },
+		}
+
+		if code := c.Run(tc.args); code != tc.code {
+			t.Fatalf("#%d expect exit code %d, got %d: %s", i, tc.code, code, ui.ErrorWriter.String())
+		}
+
+		got, _ := ioutil.ReadFile(execPath)
+		if string(got) != tc.expect {
+			t.Fatalf("#%d expect %q to eq %q", i, got, tc.expect)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b   string
+		expect int
+	}{
+		{"0.1.0", "0.1.0", 0},
+		{"0.1.0", "v0.1.0", 0},
+		{"0.1.0", "0.2.0", -1},
+		{"0.10.0", "0.9.0", 1},
+		{"1.0", "1.0.0", 0},
+		{"0.2.0-dev", "0.2.0", -1},
+		{"0.2.0+build", "0.2.0", 0},
+	}
+
+	for i, tc := range cases {
+		got, err := compareVersions(tc.a, tc.b)
+		if err != nil {
+			t.Fatalf("#%d err: %s", i, err)
+		}
+
+		if got != tc.expect {
+			t.Fatalf("#%d expect %d to eq %d", i, got, tc.expect)
+		}
+	}
+
+	if _, err := compareVersions("0.1.x", "0.1.0"); err == nil {
+		t.Fatal("expect error for invalid version")
+	}
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"text/tabwriter"
+)
+
+type VersionsCommand struct {
+	Meta
+}
+
+func (c *VersionsCommand) Run(args []string) int {
+
+	catalog, err := LoadVersionCatalog()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read version catalog: %s", err))
+		return 1
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tHYPERKUBE\tETCD")
+	for _, v := range catalog.Versions {
+		version := v.Version
+		if version == catalog.Default {
+			version += " (default)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", version, v.Hyperkube, v.Etcd)
+	}
+	w.Flush()
+
+	c.Ui.Output(buf.String())
+	return 0
+}
+
+func (c *VersionsCommand) Synopsis() string {
+	return "List kubernetes versions which can be started"
+}
+
+func (c *VersionsCommand) Help() string {
+	return "List kubernetes versions which can be started by `up -k8s-version`"
+}
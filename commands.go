@@ -18,18 +18,86 @@ func Commands(meta *command.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 
+		"forward stop": func() (cli.Command, error) {
+			return &command.ForwardStopCommand{
+				Meta: *meta,
+			}, nil
+		},
+
+		"forward status": func() (cli.Command, error) {
+			return &command.ForwardStatusCommand{
+				Meta: *meta,
+			}, nil
+		},
+
+		"forward stats": func() (cli.Command, error) {
+			return &command.ForwardStatsCommand{
+				Meta: *meta,
+			}, nil
+		},
+
+		"stop": func() (cli.Command, error) {
+			return &command.StopCommand{
+				Meta: *meta,
+			}, nil
+		},
+
+		"start": func() (cli.Command, error) {
+			return &command.StartCommand{
+				Meta: *meta,
+			}, nil
+		},
+
 		"destroy": func() (cli.Command, error) {
 			return &command.DestroyCommand{
 				Meta: *meta,
 			}, nil
 		},
 
+		"clean": func() (cli.Command, error) {
+			return &command.CleanCommand{
+				Meta: *meta,
+			}, nil
+		},
+
 		"list": func() (cli.Command, error) {
 			return &command.ListCommand{
 				Meta: *meta,
 			}, nil
 		},
 
+		"logs": func() (cli.Command, error) {
+			return &command.LogsCommand{
+				Meta: *meta,
+			}, nil
+		},
+
+		"status": func() (cli.Command, error) {
+			return &command.StatusCommand{
+				Meta: *meta,
+			}, nil
+		},
+
+		"kubeconfig": func() (cli.Command, error) {
+			return &command.KubeconfigCommand{
+				Meta: *meta,
+			}, nil
+		},
+
+		"versions": func() (cli.Command, error) {
+			return &command.VersionsCommand{
+				Meta: *meta,
+			}, nil
+		},
+
+		"upgrade": func() (cli.Command, error) {
+			return &command.UpgradeCommand{
+				Meta:    *meta,
+				Name:    Name,
+				Version: Version,
+			}, nil
+		},
+
 		"version": func() (cli.Command, error) {
 			return &command.VersionCommand{
 				Meta:     *meta,
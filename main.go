@@ -1,7 +1,48 @@
 package main
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/mitchellh/panicwrap"
+)
+
+// ExitCodeSignaled is returned by parent process when child process
+// is terminated by signal (e.g., Ctrl-C), same as shell does for SIGINT.
+const ExitCodeSignaled = 130
 
 func main() {
-	os.Exit(Run(os.Args[1:]))
+	os.Exit(realMain())
+}
+
+func realMain() int {
+	// Re-execute itself as child process and watch its panic.
+	// The crash log is written by panicHandler.
+	exitStatus, err := panicwrap.Wrap(&panicwrap.WrapConfig{
+		Handler:   panicHandler,
+		HidePanic: true,
+
+		// SIGINT is sent to the whole process group, but SIGTERM
+		// (e.g., by kill command) is only sent to parent.
+		// Setting this explicitly also prevents parent from catching
+		// every signal (empty list means all signals to signal.Notify).
+		ForwardSignals: []os.Signal{syscall.SIGTERM},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start crash reporting: %s\n", err)
+		return 1
+	}
+
+	// Child process, run actual command. Exit status can't tell it
+	// since it's also -1 when child process is terminated by signal.
+	if panicwrap.Wrapped(nil) {
+		return Run(os.Args[1:])
+	}
+
+	// Parent process, child process is already exited
+	if exitStatus < 0 {
+		return ExitCodeSignaled
+	}
+	return exitStatus
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tcnksm/boot2kubernetes/command"
+)
+
+// panicOutput is message which is shown when boot2k8s crashes.
+const panicOutput = `
+!!!!!!!!!!!!!!!!!!!!!!!!!!! BOOT2K8S CRASH !!!!!!!!!!!!!!!!!!!!!!!!!!!!
+
+boot2k8s crashed! This is always indicative of a bug within boot2k8s.
+The crash log is saved to the following file:
+
+  %s
+
+Please report the crash with the log file on GitHub issue:
+
+  https://github.com/tcnksm/boot2kubernetes/issues
+
+!!!!!!!!!!!!!!!!!!!!!!!!!!! BOOT2K8S CRASH !!!!!!!!!!!!!!!!!!!!!!!!!!!!
+`
+
+// panicHandler is called by panicwrap when child process panics.
+// It writes crash log file under ~/.boot2k8s/crash and tells its path.
+func panicHandler(output string) {
+	path, err := writeCrashLog(output, time.Now())
+	if err != nil {
+		// Can't save crash log, so show panic output directly
+		fmt.Fprintf(os.Stderr, "boot2k8s crashed and failed to write crash log: %s\n\n", err)
+		fmt.Fprintf(os.Stderr, "%s\n", output)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, panicOutput, path)
+	os.Exit(1)
+}
+
+// writeCrashLog writes panic output with information of the build and
+// the environment to timestamped file. It returns path of the file.
+func writeCrashLog(output string, now time.Time) (string, error) {
+	stateDir, err := command.StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	crashDir := filepath.Join(stateDir, "crash")
+	if err := os.MkdirAll(crashDir, 0755); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Version: %s\n", Version)
+	fmt.Fprintf(&buf, "Revision: %s\n", GitCommit)
+	fmt.Fprintf(&buf, "OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&buf, "Go: %s\n", runtime.Version())
+	fmt.Fprintf(&buf, "Args: %s\n", strings.Join(os.Args, " "))
+	fmt.Fprintf(&buf, "Time: %s\n\n", now.Format(time.RFC3339))
+	buf.WriteString(output)
+
+	path := filepath.Join(crashDir, fmt.Sprintf("crash-%s.log", now.Format("20060102-150405")))
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
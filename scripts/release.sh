@@ -62,6 +62,9 @@ pushd ./release/dist/${VERSION}
 shasum * > ./${VERSION}_SHASUMS
 popd
 
+# Latest version for mirrors of release artifacts (See "boot2k8s upgrade -help")
+echo ${VERSION} > ./release/dist/latest
+
 echo "====> Release to GitHub by tcnksm/ghr"
 # You can set ghr option via docker run option -e GHR_OPT=YOUR_OPT"
 # e.g., "-e GHR_OPT=--replace"